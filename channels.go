@@ -0,0 +1,27 @@
+package silk
+
+import "github.com/youthlin/silk/internal"
+
+// -------------------- Decode --------------------
+
+// WithChannels set decode option, duplicate the mono output to n interleaved channels(e.g. 2 for stereo),
+// for players that refuse mono; default 1.
+// 设置输出声道数, 单声道输出会复制到 n 个声道(交错排列), 用于不支持单声道的播放器; 默认值 1
+func WithChannels(n int) internal.DecodeOpt {
+	return func(dc *internal.DecodeCfg) { dc.Channels = n }
+}
+
+// -------------------- Encode --------------------
+
+// Channels set the number of interleaved channels of the input pcm, default 1.
+// Silk is mono only, multi-channel input is downmixed by averaging all channels, or see SelectChannel.
+// 设置输入 pcm 的声道数(交错排列), 默认值 1. silk 只支持单声道, 多声道输入默认取所有声道的平均值, 也可以用 SelectChannel 只保留一个声道
+func Channels(n int) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.Channels = n }
+}
+
+// SelectChannel keeps only the channel(starting from 1) of multi-channel input instead of averaging, 0 means average.
+// 多声道输入时只保留指定的声道(从 1 开始), 0 表示取平均值
+func SelectChannel(channel int) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.Channel = channel }
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/youthlin/t"
+)
+
+// FLAC 编码器(纯 Go, 不依赖 libFLAC): 每个声道独立编码, 使用固定阶数(0-4)的预测和 Rice 编码.
+// 格式参考 https://xiph.org/flac/format.html
+
+const (
+	flacBlockSize   = 4096 // 每帧的采样点数
+	flacMaxChannels = 8
+	flacMaxRiceK    = 14 // 4 位 Rice 参数, 15 是 escape code
+)
+
+func init() {
+	RegisterFormat(flacFormat{})
+}
+
+// flacFormat is FLAC with 16bit samples.
+type flacFormat struct{}
+
+func (flacFormat) Name() string         { return "flac" }
+func (flacFormat) Extensions() []string { return []string{".flac"} }
+func (flacFormat) BigEndian() bool      { return false }
+func (flacFormat) NewWriter(w io.Writer, info PCMInfo) (io.WriteCloser, error) {
+	if info.Channels > flacMaxChannels {
+		return nil, errors.New(t.T("flac supports at most %d channels", flacMaxChannels))
+	}
+	var fw = &flacWriter{w: w, info: info, md5: md5.New()}
+	if s, ok := w.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			fw.start, fw.seekable = pos, true
+		}
+	}
+	if _, err := w.Write(append([]byte("fLaC"), fw.streamInfo()...)); err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+type flacWriter struct {
+	w        io.Writer
+	info     PCMInfo
+	start    int64 // 文件在输出中的位置
+	seekable bool
+	pcm      []byte // 不足一帧的 pcm
+	md5      hash.Hash
+	frame    uint64 // frame number
+	samples  uint64 // 每个声道的采样点数
+	minFrame int
+	maxFrame int
+}
+
+// streamInfo returns the STREAMINFO metadata block, unknown fields are 0 before Close.
+func (fw *flacWriter) streamInfo() []byte {
+	var b = make([]byte, 4+34)
+	b[0] = 0x80 // last metadata block, type STREAMINFO
+	b[3] = 34
+	binary.BigEndian.PutUint16(b[4:], flacBlockSize)
+	binary.BigEndian.PutUint16(b[6:], flacBlockSize)
+	putUint24(b[8:], fw.minFrame)
+	putUint24(b[11:], fw.maxFrame)
+	binary.BigEndian.PutUint64(b[14:], uint64(fw.info.SampleRate)<<44|uint64(fw.info.Channels-1)<<41|15<<36|fw.samples)
+	if fw.samples > 0 {
+		copy(b[22:], fw.md5.Sum(nil))
+	}
+	return b
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v>>16), byte(v>>8), byte(v)
+}
+
+func (fw *flacWriter) Write(p []byte) (int, error) {
+	fw.md5.Write(p)
+	fw.pcm = append(fw.pcm, p...)
+	var blockBytes = flacBlockSize * fw.info.Channels * 2
+	var i = 0
+	for ; len(fw.pcm)-i >= blockBytes; i += blockBytes {
+		if err := fw.writeFrame(fw.pcm[i : i+blockBytes]); err != nil {
+			return 0, err
+		}
+	}
+	fw.pcm = fw.pcm[:copy(fw.pcm, fw.pcm[i:])]
+	return len(p), nil
+}
+
+// Close writes the last frame, and fills the STREAMINFO when the output is seekable.
+func (fw *flacWriter) Close() error {
+	var frameBytes = fw.info.Channels * 2
+	if n := len(fw.pcm) / frameBytes * frameBytes; n > 0 {
+		if err := fw.writeFrame(fw.pcm[:n]); err != nil {
+			return err
+		}
+	}
+	fw.pcm = nil
+	if !fw.seekable {
+		return nil
+	}
+	var s = fw.w.(io.WriteSeeker)
+	end, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = s.Seek(fw.start+4, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = s.Write(fw.streamInfo()); err != nil {
+		return err
+	}
+	_, err = s.Seek(end, io.SeekStart)
+	return err
+}
+
+// writeFrame encodes the interleaved 16bit little endian pcm as a frame.
+func (fw *flacWriter) writeFrame(pcm []byte) error {
+	var (
+		channels = fw.info.Channels
+		n        = len(pcm) / 2 / channels
+		bw       = &bitWriter{}
+	)
+	bw.write(0xFFF8, 16) // sync code, fixed block size
+	bw.write(7, 4)       // block size: 16 位(n-1)在头部末尾
+	bw.write(0, 4)       // sample rate: 见 STREAMINFO
+	bw.write(uint64(channels-1), 4)
+	bw.write(4, 3) // 16 bits per sample
+	bw.write(0, 1)
+	bw.writeUTF8(fw.frame)
+	bw.write(uint64(n-1), 16)
+	bw.write(uint64(crc8(bw.buf)), 8)
+
+	var samples = make([]int32, n)
+	for ch := 0; ch < channels; ch++ {
+		for i := range samples {
+			samples[i] = int32(int16(binary.LittleEndian.Uint16(pcm[(i*channels+ch)*2:])))
+		}
+		writeSubframe(bw, samples)
+	}
+	bw.align()
+	bw.write(uint64(crc16(bw.buf)), 16)
+
+	if _, err := fw.w.Write(bw.buf); err != nil {
+		return err
+	}
+	if size := len(bw.buf); fw.frame == 0 || size < fw.minFrame {
+		fw.minFrame = size
+	}
+	if size := len(bw.buf); size > fw.maxFrame {
+		fw.maxFrame = size
+	}
+	fw.frame++
+	fw.samples += uint64(n)
+	return nil
+}
+
+// writeSubframe writes the samples of a channel as CONSTANT, FIXED or VERBATIM subframe, whichever is smallest.
+func writeSubframe(bw *bitWriter, samples []int32) {
+	var constant = true
+	for _, s := range samples[1:] {
+		if s != samples[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		bw.write(0, 8) // padding bit, type CONSTANT, no wasted bits
+		bw.write(uint64(samples[0]), 16)
+		return
+	}
+
+	var (
+		n         = len(samples)
+		bestOrder = -1
+		bestK     int
+		bestBits  = 16 * n // VERBATIM
+		residual  = make([]uint32, n)
+	)
+	for order := 0; order <= 4 && order < n; order++ {
+		fixedResidual(samples, order, residual)
+		k, bits := riceParam(residual[order:])
+		if bits += 16*order + 2 + 4 + 4; bits < bestBits {
+			bestOrder, bestK, bestBits = order, k, bits
+		}
+	}
+	if bestOrder < 0 {
+		bw.write(1<<1, 8) // type VERBATIM
+		for _, s := range samples {
+			bw.write(uint64(s), 16)
+		}
+		return
+	}
+	bw.write(uint64(8|bestOrder)<<1, 8) // type FIXED
+	for _, s := range samples[:bestOrder] {
+		bw.write(uint64(s), 16) // warm-up samples
+	}
+	bw.write(0, 2) // residual coding method: 4 位 Rice 参数
+	bw.write(0, 4) // partition order 0
+	bw.write(uint64(bestK), 4)
+	fixedResidual(samples, bestOrder, residual)
+	for _, u := range residual[bestOrder:] {
+		bw.writeUnary(u >> bestK)
+		bw.write(uint64(u), uint(bestK))
+	}
+}
+
+// fixedResidual computes the residual of fixed predictor of the order, zigzag encoded.
+func fixedResidual(s []int32, order int, residual []uint32) {
+	for i := order; i < len(s); i++ {
+		var r int32
+		switch order {
+		case 0:
+			r = s[i]
+		case 1:
+			r = s[i] - s[i-1]
+		case 2:
+			r = s[i] - 2*s[i-1] + s[i-2]
+		case 3:
+			r = s[i] - 3*s[i-1] + 3*s[i-2] - s[i-3]
+		case 4:
+			r = s[i] - 4*s[i-1] + 6*s[i-2] - 4*s[i-3] + s[i-4]
+		}
+		residual[i] = uint32(r<<1) ^ uint32(r>>31)
+	}
+}
+
+// riceParam returns the Rice parameter with the fewest bits for the residual, and the bits.
+func riceParam(residual []uint32) (best, bestBits int) {
+	bestBits = -1
+	for k := 0; k <= flacMaxRiceK; k++ {
+		var bits = len(residual) * (k + 1)
+		for _, u := range residual {
+			bits += int(u >> k)
+		}
+		if bestBits < 0 || bits < bestBits {
+			best, bestBits = k, bits
+		}
+	}
+	return best, bestBits
+}
+
+// bitWriter writes bits MSB first.
+type bitWriter struct {
+	buf  []byte
+	acc  uint64
+	bits uint // acc 中未写入 buf 的位数
+}
+
+// write writes the low n(<= 32) bits of v.
+func (bw *bitWriter) write(v uint64, n uint) {
+	bw.acc = bw.acc<<n | v&(1<<n-1)
+	bw.bits += n
+	for bw.bits >= 8 {
+		bw.bits -= 8
+		bw.buf = append(bw.buf, byte(bw.acc>>bw.bits))
+	}
+	bw.acc &= 1<<bw.bits - 1
+}
+
+// writeUnary writes q zero bits and a one bit.
+func (bw *bitWriter) writeUnary(q uint32) {
+	for ; q >= 32; q -= 32 {
+		bw.write(0, 32)
+	}
+	bw.write(1, uint(q)+1)
+}
+
+// writeUTF8 writes v in the UTF-8 like coding of frame number.
+func (bw *bitWriter) writeUTF8(v uint64) {
+	if v < 0x80 {
+		bw.write(v, 8)
+		return
+	}
+	var n = 2 // 字节数, n 个字节可以保存 5n+1 位
+	for v >= 1<<(5*n+1) {
+		n++
+	}
+	bw.write(uint64(0xFF<<(8-n)&0xFF)|v>>(6*(n-1)), 8)
+	for i := n - 2; i >= 0; i-- {
+		bw.write(0x80|v>>(6*i)&0x3F, 8)
+	}
+}
+
+// align pads zero bits to byte boundary.
+func (bw *bitWriter) align() {
+	if bw.bits > 0 {
+		bw.write(0, 8-bw.bits)
+	}
+}
+
+// crc8 is CRC-8 with polynomial x^8 + x^2 + x^1 + x^0.
+func crc8(data []byte) uint8 {
+	var crc uint8
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// crc16 is CRC-16 with polynomial x^16 + x^15 + x^2 + x^0.
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
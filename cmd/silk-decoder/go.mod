@@ -3,7 +3,6 @@ module github.com/youthlin/silk/cmd/silk-decoder
 go 1.20
 
 require (
-	github.com/youthlin/go-lame v0.0.1
 	github.com/youthlin/silk v0.0.3
 	github.com/youthlin/t v0.0.7
 )
@@ -22,3 +21,5 @@ require (
 	golang.org/x/sys v0.0.0-20210616094352-59db8d763f22 // indirect
 	golang.org/x/text v0.3.7 // indirect
 )
+
+replace github.com/youthlin/silk => ../../
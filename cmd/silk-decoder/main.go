@@ -3,20 +3,25 @@ package main
 import (
 	"bytes"
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
-	"github.com/youthlin/go-lame"
 	"github.com/youthlin/silk"
 	"github.com/youthlin/silk/internal"
 	"github.com/youthlin/t"
 )
 
+// stdio is the file name of stdin/stdout.
+const stdio = "-"
+
 //go:embed *.po
 var poFiles embed.FS
 var (
@@ -27,7 +32,17 @@ var (
 	verboe     = flag.Bool("verbose", false, "")
 	output     = flag.String("o", "", "")
 	lang       = flag.String("l", "", "")
+	pcap       = flag.String("pcap", "", "")
+	ssrc       = flag.String("ssrc", "", "")
+	pt         = flag.Int("pt", -1, "")
+	detect     = flag.Bool("detect", false, "")
+	normalize  = flag.Float64("normalize", 0, "")
+	normMode   = flag.String("normalizeMode", "lufs", "")
+	channels   = flag.Int("channels", 1, "")
+	bigEndian  = flag.Bool("bigEndian", false, "")
+	formatName = flag.String("format", "", "")
 	pattern    *regexp.Regexp
+	outFormat  OutputFormat
 )
 
 func main() {
@@ -42,15 +57,40 @@ func main() {
 		internal.Verbose = true
 	}
 
+	if *pcap != "" { // input pcap file
+		if err := initFormat(false); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if err := decodePcapFile(*pcap); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *input == "" {
 		printUsage()
-		fmt.Println(t.T("[Error] input file are required.\n"))
+		fmt.Fprintln(os.Stderr, t.T("[Error] input file are required.\n"))
+		os.Exit(1)
+	}
+
+	if err := checkStdio(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	var process = decodeOneFile
+	if *detect { // 只识别格式, 不解码
+		process = detectOneFile
+	} else if err := initFormat(*dir != ""); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 
 	if *dir == "" { // input file
-		if err := decodeOneFile(*input, false); err != nil {
-			fmt.Println(err.Error())
+		if err := process(*input, false); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
 			os.Exit(1)
 		}
 		return
@@ -59,7 +99,7 @@ func main() {
 	// input dir
 	exp, err := regexp.Compile(*dir)
 	if err != nil {
-		fmt.Println(t.T("[Error] input file name pattern %s are invalid: %+v", *dir, err))
+		fmt.Fprintln(os.Stderr, t.T("[Error] input file name pattern %s are invalid: %+v", *dir, err))
 		os.Exit(1)
 	}
 	pattern = exp
@@ -68,60 +108,257 @@ func main() {
 		if err != nil || d.IsDir() || !pattern.MatchString(d.Name()) {
 			return nil // ignore
 		}
-		if err = decodeOneFile(path, true); err != nil {
-			fmt.Println(err.Error()) // ignore error
+		if err = process(path, true); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error()) // ignore error
 		}
 		return nil
 	})
 
 }
 
+// checkStdio checks the usage of stdin(-i -) and stdout(-o -).
+func checkStdio() error {
+	if *dir != "" && (*input == stdio || *output == stdio) {
+		return errors.New(t.T("[Error] -d can not be used with stdin or stdout"))
+	}
+	if *input == stdio && *output == "" && !*detect {
+		return errors.New(t.T("[Error] -o is required when reading from stdin(-i -)"))
+	}
+	return nil
+}
+
+// openInput opens the input file, or stdin when path is "-".
+func openInput(path string) (io.ReadCloser, error) {
+	if path == stdio {
+		return io.NopCloser(os.Stdin), nil // 隐藏 Seek, 管道不能跳转
+	}
+	return os.Open(path)
+}
+
+// initFormat selects the output format by -format, -o or -mp3.
+func initFormat(batch bool) error {
+	var mp3Set bool
+	flag.Visit(func(f *flag.Flag) { mp3Set = mp3Set || f.Name == "mp3" })
+	f, err := selectFormat(*formatName, *output, batch, defaultFormat(*mp3, mp3Set))
+	if err != nil {
+		return err
+	}
+	outFormat = f
+	return nil
+}
+
 func decodeOneFile(path string, batch bool) error {
-	in, err := os.Open(path)
+	in, err := openInput(path)
 	if err != nil {
 		return fmt.Errorf(t.T("failed to open input file %q: %w"), path, err)
 	}
 	defer in.Close()
 
-	buf, err := silk.Decode(in, silk.WithSampleRate(*sampleRate))
+	opts, err := decodeOpts()
+	if err != nil {
+		return err
+	}
+	norm, err := normalizeOpt()
+	if err != nil {
+		return err
+	}
+	if norm != nil {
+		if seeker, ok := in.(io.Seeker); ok {
+			// 文件输入两遍处理: 先测量整段响度, 再以固定增益边解码边输出, 与整段解码的结果相同
+			gain, err := silk.MeasureNormalizeGain(in, append(opts, norm)...)
+			if err != nil {
+				return fmt.Errorf(t.T("failed to decode input file %q: %w"), path, err)
+			}
+			if _, err = seeker.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf(t.T("failed to read input file %q: %w"), path, err)
+			}
+			opts = append(opts, silk.WithGain(gain))
+		} else {
+			opts = append(opts, norm) // 标准输入只能读取一遍, Decoder 使用自动增益控制
+		}
+	}
+	dec, err := silk.NewDecoder(in, opts...)
 	if err != nil {
 		return fmt.Errorf(t.T("failed to decode input file %q: %w"), path, err)
 	}
+	defer dec.Close()
+	return writeOutput(path, dec, batch)
+}
+
+// detectOneFile prints the detected format of the input file.
+func detectOneFile(path string, _ bool) error {
+	in, err := openInput(path)
+	if err != nil {
+		return fmt.Errorf(t.T("failed to open input file %q: %w"), path, err)
+	}
+	defer in.Close()
+
+	format, err := silk.DetectFormat(in)
+	if err != nil {
+		return fmt.Errorf(t.T("failed to read input file %q: %w"), path, err)
+	}
+	fmt.Printf("%s\t%s\n", path, format)
+	return nil
+}
+
+func decodePcapFile(path string) error {
+	if path == stdio && *ssrc != "" && *output == "" { // 列出 RTP 流时不需要输出文件
+		return errors.New(t.T("[Error] -o is required when reading from stdin(-pcap -)"))
+	}
+	in, err := openInput(path)
+	if err != nil {
+		return fmt.Errorf(t.T("failed to open input file %q: %w"), path, err)
+	}
+	defer in.Close()
 
-	var suffix = ".pcm"
-	if *mp3 {
-		suffix = ".mp3"
-		var out bytes.Buffer
-		wr, err := lame.NewWriter(&out)
+	if *ssrc == "" { // 没有指定 ssrc 时列出所有 RTP 流
+		streams, err := silk.PcapStreams(in)
 		if err != nil {
-			return fmt.Errorf(t.T("can not create mp3-encoder: %w"), err)
+			return fmt.Errorf(t.T("failed to read pcap file %q: %w"), path, err)
 		}
-		wr.InSampleRate = *sampleRate
-		wr.InNumChannels = 1
-		_, err = wr.Write(buf)
-		if err != nil {
-			return fmt.Errorf(t.T("failed to encode input file %q to mp3: %w"), path, err)
+		fmt.Println(t.T("RTP streams in %q:", path))
+		for _, s := range streams {
+			fmt.Println(t.T("  ssrc=%#08x payload type=%d port=%d->%d packets=%d",
+				s.SSRC, s.PayloadType, s.SrcPort, s.DstPort, s.Packets))
 		}
-		wr.Close()
-		buf = out.Bytes()
+		return errors.New(t.T("[Error] -ssrc is required when decoding pcap file"))
+	}
+	id, err := strconv.ParseUint(*ssrc, 0, 32)
+	if err != nil {
+		return fmt.Errorf(t.T("[Error] invalid ssrc %q: %w"), *ssrc, err)
+	}
+
+	opts, err := decodeOpts()
+	if err != nil {
+		return err
+	}
+	if norm, err := normalizeOpt(); err != nil {
+		return err
+	} else if norm != nil {
+		opts = append(opts, norm) // 抓包文件整段解码, 两遍处理
 	}
+	buf, err := silk.DecodePcap(in, uint32(id), append(opts, silk.WithPayloadType(*pt))...)
+	if err != nil {
+		return fmt.Errorf(t.T("failed to decode input file %q: %w"), path, err)
+	}
+	return writeOutput(path, bytes.NewReader(buf), false)
+}
+
+// decodeOpts returns the decode options from command line flags.
+func decodeOpts() ([]internal.DecodeOpt, error) {
+	if *channels < 1 {
+		return nil, errors.New(t.T("[Error] invalid number of channels: %d", *channels))
+	}
+	return []internal.DecodeOpt{
+		silk.WithSampleRate(*sampleRate),
+		silk.WithChannels(*channels),
+		silk.WithBigEndianPCM(outFormat.BigEndian()),
+	}, nil
+}
+
+// normalizeOpt returns the option of -normalize, nil if it's not set.
+func normalizeOpt() (internal.DecodeOpt, error) {
+	if *normalize == 0 {
+		return nil, nil
+	}
+	var modes = map[string]silk.NormalizeMode{
+		"lufs": silk.NormalizeLUFS,
+		"rms":  silk.NormalizeRMS,
+		"peak": silk.NormalizePeak,
+	}
+	mode, ok := modes[*normMode]
+	if !ok {
+		return nil, errors.New(t.T("[Error] invalid normalize mode %q, should be one of lufs, rms, peak", *normMode))
+	}
+	return silk.WithNormalize(mode, *normalize), nil
+}
 
-	var outputName = getOutputName(path, suffix, *output, batch)
+// writeOutput encodes the pcm read from src to output file in the output format, block by block.
+// The output is written to a temporary file and renamed at last, so no truncated output is left on error;
+// stdout(-o -) is written directly.
+func writeOutput(path string, src io.Reader, batch bool) error {
+	var outputName = getOutputName(path, outFormat.Extensions()[0], *output, batch)
+	if outputName == stdio {
+		return encodeOutput(path, src, os.Stdout, "stdout")
+	}
 
-	out, err := os.OpenFile(outputName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	out, err := createTemp(outputName)
 	if err != nil {
 		return fmt.Errorf(t.T("failed to open/create output file %q: %w"), outputName, err)
+	}
+	defer func() {
+		if out != nil { // 出错时删除临时文件
+			out.Close()
+			os.Remove(out.Name())
+		}
+	}()
+
+	if err = encodeOutput(path, src, out, outputName); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return fmt.Errorf(t.T("failed to write output file %q: %w"), outputName, err)
+	}
+	if err = os.Rename(out.Name(), outputName); err != nil {
+		return fmt.Errorf(t.T("failed to write output file %q: %w"), outputName, err)
+	}
+	out = nil
+	return nil
+}
 
+// createTemp creates a temporary file in the folder of name, which will be renamed to name.
+// The file has the permission of the existing name, otherwise 0666 masked by umask like os.Create.
+// 在 name 所在目录创建临时文件, 权限与已有的 name 相同, 不存在时与 os.Create 一样是 0666 去掉 umask
+func createTemp(name string) (*os.File, error) {
+	var perm = 0666 &^ umask()
+	if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+		perm = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return nil, err
+	}
+	if err = f.Chmod(perm); err != nil { // CreateTemp 创建的文件权限是 0600
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
 	}
-	defer out.Close()
+	return f, nil
+}
 
-	_, err = out.Write(buf)
+// encodeOutput encodes the pcm read from src to w in the output format.
+func encodeOutput(path string, src io.Reader, w io.Writer, outputName string) error {
+	enc, err := outFormat.NewWriter(w, PCMInfo{SampleRate: *sampleRate, Channels: *channels, Source: path})
 	if err != nil {
+		return fmt.Errorf(t.T("failed to encode input file %q to %s: %w"), path, outFormat.Name(), err)
+	}
+	if _, err = io.Copy(enc, decodeReader{src}); err != nil {
+		enc.Close()
+		var de decodeError
+		if errors.As(err, &de) {
+			return fmt.Errorf(t.T("failed to decode input file %q: %w"), path, de.error)
+		}
+		return fmt.Errorf(t.T("failed to write output file %q: %w"), outputName, err)
+	}
+	if err = enc.Close(); err != nil {
 		return fmt.Errorf(t.T("failed to write output file %q: %w"), outputName, err)
 	}
 	return nil
 }
 
+// decodeReader marks the errors of src as decodeError, to tell them from the errors of output.
+type decodeReader struct{ src io.Reader }
+
+type decodeError struct{ error }
+
+func (r decodeReader) Read(p []byte) (int, error) {
+	n, err := r.src.Read(p)
+	if err != nil && err != io.EOF {
+		err = decodeError{err}
+	}
+	return n, err
+}
+
 func getOutputName(path, suffix, output string, batch bool) string {
 	var outputName string
 	if batch { // 批量
@@ -153,28 +390,49 @@ func getOutputName(path, suffix, output string, batch bool) string {
 
 func printUsage() {
 	var name = os.Args[0]
-	fmt.Println()
-	fmt.Println(t.T("Silk decoder, Go version, based on v1.0.9 of C version"))
-	fmt.Println(t.T("Decode silk v3 file to pcm or mp3, by youthlin"))
-	fmt.Println(t.T("GitHub: https://github.comyouthlin/silk"))
-	fmt.Println()
-	fmt.Println(t.T("Usage: %s -i <input file> [settings]", name))
-	fmt.Println(t.T("  -i <input file>\tInput file or input folder(should with -d settings)"))
-	fmt.Println(t.T("  [settings]"))
-	fmt.Println(t.T("    -d <pattern>\tInput is a dir, and use the regexp <pattern> to test input file"))
-	fmt.Println(t.T("    -sampleRate <hz>\tSample rate in Hz, default 24000"))
-	fmt.Println(t.T("    -mp3[=false]\tOutput as mp3 file, default true, set false to output as pcm file"))
-	fmt.Println(t.T("    -o <output file>\tOutput file name, or output file extension name when input is folder.\n\t\t\tIf not provide, output name is <input>.mp3 or <input>.pcm(when -mp3=false)"))
-	fmt.Println(t.T("    -l <language>\tLanguage path(pointer to po file/dir)"))
-	fmt.Println(t.T("    -verbose\t\tprint verbose log(default false)"))
-	fmt.Println()
-	fmt.Println(t.T("Example:"))
-	fmt.Println(t.X("cmd-example", "%s -i a.amr\n\tdecode a.amr to a.mp3", name))
-	fmt.Println(t.X("cmd-example", "%s -i amr.1\n\tdecode amr.1 to amr.mp3", name))
-	fmt.Println(t.X("cmd-example", "%s -i file\n\tdecode file to file.mp3", name))
-	fmt.Println(t.X("cmd-example", "%s -i a.amr -o b.mp3\n\tdecode a.amr to b.mp3", name))
-	fmt.Println(t.X("cmd-example", "%s -i a.amr -mp3=false\n\tdecode a.amr to a.pcm", name))
-	fmt.Println(t.X("cmd-example", "%s -i a.amr -mp3=false -o b.pcm\n\tdecode a.amr to b.pcm", name))
-	fmt.Println(t.X("cmd-example", "%s -i voice -d \".*\\.amr\"\n\tdecode files in the folder to mp3\n\t  e.g.: if the voice folder has these files:\n\t\tvoice/a.amr\n\t\tvoice/other.txt\n\t\tvoice/sub/b.amr\n\t  result:\n\t\tvoice/a.mp3\n\t\tvoice/sub/b.mp3", name))
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, t.T("Silk decoder, Go version, based on v1.0.9 of C version"))
+	fmt.Fprintln(os.Stderr, t.T("Decode silk v3 file to pcm or mp3, by youthlin"))
+	fmt.Fprintln(os.Stderr, t.T("GitHub: https://github.comyouthlin/silk"))
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, t.T("Usage: %s -i <input file> [settings]", name))
+	fmt.Fprintln(os.Stderr, t.T("  -i <input file>\tInput file or input folder(should with -d settings), - for stdin(-o is required)"))
+	fmt.Fprintln(os.Stderr, t.T("  [settings]"))
+	fmt.Fprintln(os.Stderr, t.T("    -d <pattern>\tInput is a dir, and use the regexp <pattern> to test input file"))
+	fmt.Fprintln(os.Stderr, t.T("    -sampleRate <hz>\tSample rate in Hz, default 24000"))
+	fmt.Fprintln(os.Stderr, t.T("    -format <format>\tOutput format: %s.\n\t\t\tIf not provide, inferred from the extension of -o, or %s(pcm when -mp3=false)", formatNames(), defaultFormat(true, false)))
+	fmt.Fprintln(os.Stderr, t.T("    -mp3[=false]\tOutput as mp3 file when the format is not specified, default true(wav when built without lame), set false to output as pcm file"))
+	fmt.Fprintln(os.Stderr, t.T("    -mp3-bitrate <kbps>\tBitrate of mp3 output in kbps(e.g. 64), default: 0(LAME default, 128)"))
+	fmt.Fprintln(os.Stderr, t.T("    -mp3-vbr <q>\tUse VBR with the quality 0(best) - 9(smallest) instead of -mp3-bitrate, default: -1(CBR)"))
+	fmt.Fprintln(os.Stderr, t.T("    -mp3-quality <q>\tQuality of mp3 encoding, 0(best, slowest) - 9(fastest), default: -1(LAME default)"))
+	fmt.Fprintln(os.Stderr, t.T("    -mp3-outrate <hz>\tSample rate of mp3 output, default: 0(chosen by LAME)"))
+	fmt.Fprintln(os.Stderr, t.T("    -mp3-title <text>\n    -mp3-artist <text>\n    -mp3-comment <text>\n\t\t\tID3v2 tags of mp3 output, {name}, {file}, {dir} are replaced by the input\n\t\t\tfile name without extension, file name and folder name, e.g. -mp3-title {name}"))
+	fmt.Fprintln(os.Stderr, t.T("    -o <output file>\tOutput file name, or output file extension name when input is folder.\n\t\t\tIf not provide, output name is <input> with the extension of output format, e.g. <input>.mp3.\n\t\t\t- for stdout, -format is required"))
+	fmt.Fprintln(os.Stderr, t.T("    -pcap <pcap file>\tDecode silk RTP stream in pcap/pcapng file, use it instead of -i, - for stdin"))
+	fmt.Fprintln(os.Stderr, t.T("    -ssrc <ssrc>\tSSRC of the RTP stream to decode, list all streams if not provide"))
+	fmt.Fprintln(os.Stderr, t.T("    -pt <type>\t\tRTP payload type of the stream, default any"))
+	fmt.Fprintln(os.Stderr, t.T("    -channels <n>\tNumber of output channels, the mono output is duplicated(e.g. 2 for stereo), default 1"))
+	fmt.Fprintln(os.Stderr, t.T("    -bigEndian\t\tOutput big endian pcm(only for pcm format), default false"))
+	fmt.Fprintln(os.Stderr, t.T("    -normalize <level>\tNormalize loudness to the level(e.g. -16), default: 0(disabled).\n\t\t\tFiles are measured first and decoded with a constant gain limited by the true peak ceiling;\n\t\t\tstdin(-i -) can be read only once, the gain is adjusted gradually while decoding(AGC, clipped at the ceiling)"))
+	fmt.Fprintln(os.Stderr, t.T("    -normalizeMode <mode>\tLoudness measurement: lufs(EBU R128 integrated loudness, short-term with AGC), rms or peak(dBFS),\n\t\t\tthe ceiling is -1 dBTP, default: lufs"))
+	fmt.Fprintln(os.Stderr, t.T("    -detect\t\tOnly detect and print the format of input file(s), do not decode"))
+	fmt.Fprintln(os.Stderr, t.T("    -l <language>\tLanguage path(pointer to po file/dir)"))
+	fmt.Fprintln(os.Stderr, t.T("    -verbose\t\tprint verbose log(default false)"))
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, t.T("Example:"))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i a.amr\n\tdecode a.amr to a.mp3", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i amr.1\n\tdecode amr.1 to amr.mp3", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i file\n\tdecode file to file.mp3", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i a.amr -o b.mp3\n\tdecode a.amr to b.mp3", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i a.amr -mp3=false\n\tdecode a.amr to a.pcm", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i a.amr -mp3=false -o b.pcm\n\tdecode a.amr to b.pcm", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i a.amr -o a.wav\n\tdecode a.amr to a.wav", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i a.amr -format flac\n\tdecode a.amr to a.flac", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i a.amr -mp3-vbr 4 -mp3-title {name} -mp3-artist Alice\n\tdecode a.amr to VBR a.mp3, with title a and artist Alice", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i a.amr -normalize -16\n\tdecode a.amr to a.mp3, and normalize the loudness to -16 LUFS", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "cat a.amr | %s -i - -o - -format wav | ffplay -\n\tdecode from stdin and write wav to stdout", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -pcap call.pcap -ssrc 0x1234abcd\n\tdecode the RTP stream in call.pcap to call.mp3", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i voice -d \".*\" -detect\n\tprint the format of all files in the folder", name))
+	fmt.Fprintln(os.Stderr, t.X("cmd-example", "%s -i voice -d \".*\\.amr\"\n\tdecode files in the folder to mp3\n\t  e.g.: if the voice folder has these files:\n\t\tvoice/a.amr\n\t\tvoice/other.txt\n\t\tvoice/sub/b.amr\n\t  result:\n\t\tvoice/a.mp3\n\t\tvoice/sub/b.mp3", name))
+	fmt.Fprintln(os.Stderr)
 }
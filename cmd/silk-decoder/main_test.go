@@ -1,6 +1,10 @@
 package main
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func Test_getOutputName(t *testing.T) {
 	type args struct {
@@ -32,3 +36,39 @@ func Test_getOutputName(t *testing.T) {
 		})
 	}
 }
+
+func Test_createTemp(t *testing.T) {
+	var dir = t.TempDir()
+	mode := func(name string) os.FileMode {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+	// 新文件与 os.Create 的权限相同
+	f, err := os.Create(filepath.Join(dir, "ref"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if f, err = createTemp(filepath.Join(dir, "new.mp3")); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if got, want := mode(f.Name()), mode(filepath.Join(dir, "ref")); got != want {
+		t.Errorf("createTemp() mode = %v, want %v", got, want)
+	}
+	// 已有文件保留原来的权限
+	var existing = filepath.Join(dir, "old.mp3")
+	if err = os.WriteFile(existing, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = createTemp(existing); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if got, want := mode(f.Name()), mode(existing); got != want {
+		t.Errorf("createTemp() mode = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/youthlin/silk"
+	"github.com/youthlin/silk/internal"
+	"github.com/youthlin/t"
+)
+
+// MP3 输出依赖 libmp3lame, 需要安装 libmp3lame-dev 并使用 -tags lame 编译, 见 internal/mp3_lame.go.
+// 没有编译 mp3 支持时, 默认输出 wav 格式.
+
+var (
+	mp3Bitrate = flag.Int("mp3-bitrate", 0, "")
+	mp3VBR     = flag.Int("mp3-vbr", -1, "")
+	mp3Quality = flag.Int("mp3-quality", -1, "")
+	mp3OutRate = flag.Int("mp3-outrate", 0, "")
+	mp3Title   = flag.String("mp3-title", "", "")
+	mp3Artist  = flag.String("mp3-artist", "", "")
+	mp3Comment = flag.String("mp3-comment", "", "")
+)
+
+// mp3Supported is true when built with lame tag.
+const mp3Supported = silk.MP3Supported
+
+func init() {
+	RegisterFormat(mp3Format{})
+}
+
+// mp3Format is MP3 encoded by LAME.
+type mp3Format struct{}
+
+func (mp3Format) Name() string         { return "mp3" }
+func (mp3Format) Extensions() []string { return []string{".mp3"} }
+func (mp3Format) BigEndian() bool      { return false }
+
+// Check returns an error when mp3 support is not compiled in.
+func (mp3Format) Check() error {
+	if !mp3Supported {
+		return errors.New(t.T("[Error] mp3 output is not supported: this silk-decoder is built without lame.\n\tInstall libmp3lame-dev and rebuild with -tags lame, or use another format, e.g. -format wav"))
+	}
+	return nil
+}
+
+func (f mp3Format) NewWriter(w io.Writer, info PCMInfo) (io.WriteCloser, error) {
+	if err := f.Check(); err != nil {
+		return nil, err
+	}
+	var opts = []internal.MP3Opt{
+		silk.MP3Input(info.SampleRate, info.Channels),
+		silk.MP3Bitrate(*mp3Bitrate),
+		silk.MP3VBR(*mp3VBR),
+		silk.MP3Quality(*mp3Quality),
+		silk.MP3OutSampleRate(*mp3OutRate),
+	}
+	if *mp3Title != "" || *mp3Artist != "" || *mp3Comment != "" {
+		opts = append(opts, silk.MP3ID3(silk.ID3Tags{
+			Title:   expandTemplate(*mp3Title, info.Source),
+			Artist:  expandTemplate(*mp3Artist, info.Source),
+			Comment: expandTemplate(*mp3Comment, info.Source),
+		}))
+	}
+	return silk.NewMP3Writer(w, opts...)
+}
+
+// expandTemplate replaces {name}, {file}, {dir} in tmpl with the input file name without extension,
+// the file name and the folder.
+func expandTemplate(tmpl, path string) string {
+	var file = filepath.Base(path)
+	return strings.NewReplacer(
+		"{name}", strings.TrimSuffix(file, filepath.Ext(file)),
+		"{file}", file,
+		"{dir}", filepath.Base(filepath.Dir(path)),
+	).Replace(tmpl)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/youthlin/t"
+)
+
+// 输出格式: 解码得到的 16 位 pcm 由 OutputFormat 编码为输出文件.
+// 每种格式在 init 时注册, 自己的参数(如 -mp3-bitrate)也在各自的文件中定义, 新增格式不需要修改解码逻辑.
+
+// PCMInfo describes the 16bit pcm written to the output writer.
+type PCMInfo struct {
+	SampleRate int
+	Channels   int
+	Source     string // path of the input file, e.g. used by mp3 tags
+}
+
+// OutputFormat encodes the decoded 16bit pcm to an output file format.
+// 输出格式
+type OutputFormat interface {
+	Name() string         // name used by -format, e.g. wav
+	Extensions() []string // file extensions, the first one is used for output file name, e.g. .wav
+	BigEndian() bool      // the pcm written to the writer is big endian
+	// NewWriter returns a writer that encodes the pcm to w, Close finishes the output but does not close w.
+	// When w is seekable(a file), the sizes in the header are filled on Close.
+	NewWriter(w io.Writer, info PCMInfo) (io.WriteCloser, error)
+}
+
+// formatChecker is implemented by the formats which may be unavailable, e.g. mp3 when built without lame.
+type formatChecker interface {
+	Check() error
+}
+
+// formats are the registered output formats, the formats in other files are registered in their init.
+var formats = []OutputFormat{rawFormat{}, wavFormat{}, aiffFormat{}, auFormat{}}
+
+// RegisterFormat registers an output format, the later one replaces the former one with the same name.
+func RegisterFormat(f OutputFormat) {
+	for i, old := range formats {
+		if old.Name() == f.Name() {
+			formats[i] = f
+			return
+		}
+	}
+	formats = append(formats, f)
+}
+
+// lookupFormat returns the format by name, nil if not found.
+func lookupFormat(name string) OutputFormat {
+	for _, f := range formats {
+		if strings.EqualFold(f.Name(), name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// formatByExt returns the format by file extension(with or without dot), nil if not found.
+func formatByExt(ext string) OutputFormat {
+	if ext == "" {
+		return nil
+	}
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	for _, f := range formats {
+		for _, e := range f.Extensions() {
+			if strings.EqualFold(e, ext) {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// formatNames returns the names of all registered formats.
+func formatNames() string {
+	var names = make([]string, 0, len(formats))
+	for _, f := range formats {
+		names = append(names, f.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// selectFormat selects the output format by name, or infers it from the extension of output,
+// or uses defaultName. In batch mode output is the extension of output files.
+// The name is required when output is stdout.
+func selectFormat(name, output string, batch bool, defaultName string) (OutputFormat, error) {
+	var f OutputFormat
+	if output == stdio && name == "" {
+		return nil, errors.New(t.T("[Error] -format is required when writing to stdout(-o -)"))
+	}
+	if name != "" {
+		f = lookupFormat(name)
+	} else {
+		var ext = filepath.Ext(output)
+		if batch && ext == "" {
+			ext = output
+		}
+		if f = formatByExt(ext); f == nil {
+			name = defaultName
+			f = lookupFormat(name)
+		}
+	}
+	if f == nil {
+		return nil, errors.New(t.T("[Error] unknown output format %q, should be one of %s", name, formatNames()))
+	}
+	if c, ok := f.(formatChecker); ok {
+		if err := c.Check(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// defaultFormat returns the name of output format when it is not specified:
+// mp3, or pcm when -mp3=false, or wav when mp3 is not supported and -mp3 is not set explicitly.
+func defaultFormat(mp3, mp3Set bool) string {
+	if !mp3 {
+		return "pcm"
+	}
+	if !mp3Supported && !mp3Set {
+		return "wav"
+	}
+	return "mp3"
+}
+
+// rawFormat is the raw pcm without header, the byte order is set by -bigEndian.
+type rawFormat struct{}
+
+func (rawFormat) Name() string         { return "pcm" }
+func (rawFormat) Extensions() []string { return []string{".pcm", ".raw"} }
+func (rawFormat) BigEndian() bool      { return *bigEndian }
+func (rawFormat) NewWriter(w io.Writer, _ PCMInfo) (io.WriteCloser, error) {
+	return nopCloser{w}, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// headerWriter writes a header with placeholder sizes, then the pcm,
+// and fills the sizes on Close when the output is seekable. 不可 seek 时(如管道)保留占位的长度
+type headerWriter struct {
+	w        io.Writer
+	start    int64 // 文件头在输出中的位置
+	seekable bool
+	n        int64 // pcm 字节数
+	patch    func(n int64) []byte
+}
+
+func newHeaderWriter(w io.Writer, header []byte, patch func(n int64) []byte) (io.WriteCloser, error) {
+	var hw = &headerWriter{w: w, patch: patch}
+	if s, ok := w.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			hw.start, hw.seekable = pos, true
+		}
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return hw, nil
+}
+
+func (hw *headerWriter) Write(p []byte) (int, error) {
+	n, err := hw.w.Write(p)
+	hw.n += int64(n)
+	return n, err
+}
+
+func (hw *headerWriter) Close() error {
+	if !hw.seekable {
+		return nil
+	}
+	var s = hw.w.(io.WriteSeeker)
+	end, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = s.Seek(hw.start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = s.Write(hw.patch(hw.n)); err != nil {
+		return err
+	}
+	_, err = s.Seek(end, io.SeekStart)
+	return err
+}
+
+// size32 returns n+extra as 32bit size, the max value means unknown(n < 0) or too large.
+func size32(n, extra int64) uint32 {
+	if n < 0 || n+extra > 0xFFFFFFFF {
+		return 0xFFFFFFFF
+	}
+	return uint32(n + extra)
+}
+
+// wavFormat is RIFF WAVE with 16bit little endian pcm.
+type wavFormat struct{}
+
+func (wavFormat) Name() string         { return "wav" }
+func (wavFormat) Extensions() []string { return []string{".wav"} }
+func (wavFormat) BigEndian() bool      { return false }
+func (wavFormat) NewWriter(w io.Writer, info PCMInfo) (io.WriteCloser, error) {
+	var header = func(n int64) []byte {
+		var (
+			h          = make([]byte, 44)
+			blockAlign = info.Channels * 2
+		)
+		copy(h, "RIFF")
+		binary.LittleEndian.PutUint32(h[4:], size32(n, 36))
+		copy(h[8:], "WAVEfmt ")
+		binary.LittleEndian.PutUint32(h[16:], 16)
+		binary.LittleEndian.PutUint16(h[20:], 1) // PCM
+		binary.LittleEndian.PutUint16(h[22:], uint16(info.Channels))
+		binary.LittleEndian.PutUint32(h[24:], uint32(info.SampleRate))
+		binary.LittleEndian.PutUint32(h[28:], uint32(info.SampleRate*blockAlign))
+		binary.LittleEndian.PutUint16(h[32:], uint16(blockAlign))
+		binary.LittleEndian.PutUint16(h[34:], 16)
+		copy(h[36:], "data")
+		binary.LittleEndian.PutUint32(h[40:], size32(n, 0))
+		return h
+	}
+	return newHeaderWriter(w, header(-1), header)
+}
+
+// aiffFormat is AIFF with 16bit big endian pcm.
+type aiffFormat struct{}
+
+func (aiffFormat) Name() string         { return "aiff" }
+func (aiffFormat) Extensions() []string { return []string{".aiff", ".aif"} }
+func (aiffFormat) BigEndian() bool      { return true }
+func (aiffFormat) NewWriter(w io.Writer, info PCMInfo) (io.WriteCloser, error) {
+	var header = func(n int64) []byte {
+		var (
+			h      = make([]byte, 54)
+			frames = int64(-1)
+		)
+		if n >= 0 {
+			frames = n / int64(info.Channels*2)
+		}
+		copy(h, "FORM")
+		binary.BigEndian.PutUint32(h[4:], size32(n, 46))
+		copy(h[8:], "AIFFCOMM")
+		binary.BigEndian.PutUint32(h[16:], 18)
+		binary.BigEndian.PutUint16(h[20:], uint16(info.Channels))
+		binary.BigEndian.PutUint32(h[22:], size32(frames, 0))
+		binary.BigEndian.PutUint16(h[26:], 16)
+		putExtended(h[28:38], info.SampleRate)
+		copy(h[38:], "SSND")
+		binary.BigEndian.PutUint32(h[42:], size32(n, 8))
+		// offset 和 block size 都是 0
+		return h
+	}
+	return newHeaderWriter(w, header(-1), header)
+}
+
+// putExtended puts the positive integer v as 80bit IEEE 754 extended precision float.
+func putExtended(b []byte, v int) {
+	var (
+		exp      = 16383 + 63
+		mantissa = uint64(v)
+	)
+	for mantissa != 0 && mantissa&(1<<63) == 0 {
+		mantissa <<= 1
+		exp--
+	}
+	binary.BigEndian.PutUint16(b, uint16(exp))
+	binary.BigEndian.PutUint64(b[2:], mantissa)
+}
+
+// auFormat is Sun/NeXT au with 16bit big endian pcm.
+type auFormat struct{}
+
+func (auFormat) Name() string         { return "au" }
+func (auFormat) Extensions() []string { return []string{".au", ".snd"} }
+func (auFormat) BigEndian() bool      { return true }
+func (auFormat) NewWriter(w io.Writer, info PCMInfo) (io.WriteCloser, error) {
+	var header = func(n int64) []byte {
+		var h = make([]byte, 24)
+		copy(h, ".snd")
+		binary.BigEndian.PutUint32(h[4:], 24)
+		binary.BigEndian.PutUint32(h[8:], size32(n, 0)) // 0xFFFFFFFF 表示长度未知
+		binary.BigEndian.PutUint32(h[12:], 3)           // 16 位线性 pcm
+		binary.BigEndian.PutUint32(h[16:], uint32(info.SampleRate))
+		binary.BigEndian.PutUint32(h[20:], uint32(info.Channels))
+		return h
+	}
+	return newHeaderWriter(w, header(-1), header)
+}
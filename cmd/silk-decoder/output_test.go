@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_selectFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		output string
+		batch  bool
+		want   string
+	}{
+		{"default", "", "", false, "pcm"},
+		{"format", "FLAC", "a.wav", false, "flac"},
+		{"output-ext", "", "b.wav", false, "wav"},
+		{"output-unknown-ext", "", "some.bit", false, "pcm"},
+		{"batch-ext", "", "aif", true, "aiff"},
+		{"batch-dotext", "", ".au", true, "au"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := selectFormat(tt.format, tt.output, tt.batch, "pcm")
+			if err != nil || f.Name() != tt.want {
+				t.Errorf("selectFormat() = %v, %v, want %v", f, err, tt.want)
+			}
+		})
+	}
+	if _, err := selectFormat("ogg", "", false, "pcm"); err == nil {
+		t.Errorf("unknown format should be error")
+	}
+	// 输出到 stdout 时必须指定格式
+	if _, err := selectFormat("", "-", false, "pcm"); err == nil {
+		t.Errorf("stdout without format should be error")
+	}
+	if f, err := selectFormat("wav", "-", false, "pcm"); err != nil || f.Name() != "wav" {
+		t.Errorf("selectFormat(wav, -) = %v, %v", f, err)
+	}
+	// 没有编译 mp3 支持时, 默认输出 wav, 明确指定 mp3 时报错
+	if got := defaultFormat(true, false); mp3Supported && got != "mp3" || !mp3Supported && got != "wav" {
+		t.Errorf("defaultFormat() = %v", got)
+	}
+	if got := defaultFormat(false, true); got != "pcm" {
+		t.Errorf("defaultFormat(-mp3=false) = %v", got)
+	}
+	for _, args := range [][]string{{"mp3", ""}, {"", "a.mp3"}, {"", defaultFormat(true, true)}} {
+		_, err := selectFormat(args[0], args[1], false, defaultFormat(true, true))
+		if mp3Supported != (err == nil) {
+			t.Errorf("select mp3 %v: mp3Supported=%v, err=%v", args, mp3Supported, err)
+		}
+	}
+}
+
+func Test_expandTemplate(t *testing.T) {
+	var path = filepath.Join("voice", "sub", "b.amr")
+	if got := expandTemplate("{name} - {file} @{dir}", path); got != "b - b.amr @sub" {
+		t.Errorf("expandTemplate() = %q", got)
+	}
+	if got := expandTemplate("Alice", path); got != "Alice" {
+		t.Errorf("expandTemplate() = %q", got)
+	}
+}
+
+// sine returns 16bit interleaved pcm of a sine wave.
+func sine(samples, channels int, bigEndian bool) []byte {
+	var pcm = make([]byte, samples*channels*2)
+	for i := 0; i < samples; i++ {
+		for ch := 0; ch < channels; ch++ {
+			var v = uint16(int16(8000 * math.Sin(float64(i*(ch+1))*0.05)))
+			if bigEndian {
+				binary.BigEndian.PutUint16(pcm[(i*channels+ch)*2:], v)
+			} else {
+				binary.LittleEndian.PutUint16(pcm[(i*channels+ch)*2:], v)
+			}
+		}
+	}
+	return pcm
+}
+
+// encodeFile encodes pcm to a file(seekable) and to a buffer(not seekable).
+func encodeFile(t *testing.T, f OutputFormat, pcm []byte, info PCMInfo) (file, stream []byte) {
+	t.Helper()
+	var name = filepath.Join(t.TempDir(), "out"+f.Extensions()[0])
+	out, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	var buf bytes.Buffer
+	for _, w := range []io.Writer{out, &buf} {
+		enc, err := f.NewWriter(w, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(pcm); i += 1001 { // 分多次写入
+			if _, err = enc.Write(pcm[i:min(i+1001, len(pcm))]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file, err = os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file, buf.Bytes()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func TestHeaderFormats(t *testing.T) {
+	var info = PCMInfo{SampleRate: 24000, Channels: 2}
+	for _, tt := range []struct {
+		format     string
+		headerLen  int
+		sizes      map[int]uint32 // offset -> size
+		bigEndian  bool
+		rateOffset int
+	}{
+		{"wav", 44, map[int]uint32{4: 36, 40: 0}, false, 24},
+		{"aiff", 54, map[int]uint32{4: 46, 22: 0, 42: 8}, true, -1},
+		{"au", 24, map[int]uint32{8: 0}, true, 16},
+	} {
+		var (
+			f   = lookupFormat(tt.format)
+			pcm = sine(1000, info.Channels, f.BigEndian())
+		)
+		file, stream := encodeFile(t, f, pcm, info)
+		if len(file) != tt.headerLen+len(pcm) || !bytes.Equal(file[tt.headerLen:], pcm) {
+			t.Errorf("%s: unexpected output: %d bytes", tt.format, len(file))
+			continue
+		}
+		var order binary.ByteOrder = binary.LittleEndian
+		if tt.bigEndian {
+			order = binary.BigEndian
+		}
+		for offset, extra := range tt.sizes {
+			var want = uint32(len(pcm)) + extra
+			if offset == 22 { // aiff 的采样帧数
+				want = 1000
+			}
+			if got := order.Uint32(file[offset:]); got != want {
+				t.Errorf("%s: size at %d = %d, want %d", tt.format, offset, got, want)
+			}
+			// 不可 seek 时保留占位的长度
+			if got := order.Uint32(stream[offset:]); got != 0xFFFFFFFF {
+				t.Errorf("%s: stream size at %d = %#x, want unknown", tt.format, offset, got)
+			}
+		}
+		if tt.rateOffset > 0 && order.Uint32(file[tt.rateOffset:]) != 24000 {
+			t.Errorf("%s: invalid sample rate", tt.format)
+		}
+	}
+	// aiff 的采样率是 80 位浮点数
+	var b = make([]byte, 10)
+	putExtended(b, 44100)
+	if !bytes.Equal(b, []byte{0x40, 0x0E, 0xAC, 0x44, 0, 0, 0, 0, 0, 0}) {
+		t.Errorf("putExtended(44100) = %x", b)
+	}
+}
+
+func TestFLAC(t *testing.T) {
+	for _, channels := range []int{1, 2} {
+		var (
+			info = PCMInfo{SampleRate: 16000, Channels: channels}
+			pcm  = append(sine(flacBlockSize*2+123, channels, false), make([]byte, flacBlockSize*channels*2)...) // 最后是静音
+		)
+		pcm = append(pcm, sine(50, channels, false)...)
+		file, stream := encodeFile(t, flacFormat{}, pcm, info)
+		got, total, sum := decodeFLAC(t, file)
+		if !bytes.Equal(got, pcm) {
+			t.Fatalf("channels=%d: decoded %d bytes, want %d", channels, len(got), len(pcm))
+		}
+		if want := uint64(len(pcm) / 2 / channels); total != want || sum != md5.Sum(pcm) {
+			t.Errorf("channels=%d: total samples=%d, want %d, md5 %x", channels, total, want, sum)
+		}
+		if got, total, _ = decodeFLAC(t, stream); !bytes.Equal(got, pcm) || total != 0 {
+			t.Errorf("channels=%d: stream decoded %d bytes, total samples=%d", channels, len(got), total)
+		}
+		if len(file) > len(pcm)/2 {
+			t.Errorf("channels=%d: flac %d bytes is not compressed, pcm %d bytes", channels, len(file), len(pcm))
+		}
+	}
+}
+
+// decodeFLAC decodes the subset of FLAC written by flacWriter.
+func decodeFLAC(t *testing.T, data []byte) (pcm []byte, total uint64, sum [16]byte) {
+	t.Helper()
+	if string(data[:4]) != "fLaC" || data[4] != 0x80 {
+		t.Fatalf("invalid flac header %x", data[:8])
+	}
+	var (
+		info     = binary.BigEndian.Uint64(data[18:])
+		channels = int(info>>41&7) + 1
+	)
+	total = info & (1<<36 - 1)
+	copy(sum[:], data[26:42])
+	data = data[42:]
+	for len(data) > 0 {
+		var r = &bitReader{data: data}
+		if r.read(16) != 0xFFF8 || r.read(4) != 7 || r.read(4) != 0 || int(r.read(4)) != channels-1 || r.read(4) != 8 {
+			t.Fatalf("invalid frame header %x", data[:8])
+		}
+		var first = r.read(8) // frame number, 第一个字节开头 1 的个数是总字节数
+		for mask := uint64(0x40); first&0x80 != 0 && first&mask != 0; mask >>= 1 {
+			r.read(8)
+		}
+		var n = int(r.read(16)) + 1
+		if crc := crc8(data[:r.pos/8]); uint8(r.read(8)) != crc {
+			t.Fatalf("invalid header crc")
+		}
+		var samples = make([][]int32, channels)
+		for ch := range samples {
+			samples[ch] = r.subframe(n)
+		}
+		r.pos = (r.pos + 7) / 8 * 8
+		var end = r.pos/8 + 2
+		if crc16(data[:end-2]) != uint16(r.read(16)) {
+			t.Fatalf("invalid frame crc")
+		}
+		for i := 0; i < n; i++ {
+			for ch := range samples {
+				pcm = binary.LittleEndian.AppendUint16(pcm, uint16(samples[ch][i]))
+			}
+		}
+		data = data[end:]
+	}
+	return pcm, total, sum
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		v = v<<1 | uint64(r.data[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) subframe(n int) []int32 {
+	var (
+		header  = r.read(8)
+		kind    = header >> 1 & 0x3F
+		samples = make([]int32, n)
+		sample  = func() int32 { return int32(int16(r.read(16))) }
+	)
+	switch {
+	case kind == 0:
+		var v = sample()
+		for i := range samples {
+			samples[i] = v
+		}
+	case kind == 1:
+		for i := range samples {
+			samples[i] = sample()
+		}
+	case kind >= 8 && kind <= 12:
+		var order = int(kind - 8)
+		for i := 0; i < order; i++ {
+			samples[i] = sample()
+		}
+		r.read(2 + 4)
+		var k = int(r.read(4))
+		for i := order; i < n; i++ {
+			var q uint64
+			for r.read(1) == 0 {
+				q++
+			}
+			var u = uint32(q<<k | r.read(k))
+			var res = int32(u>>1) ^ -int32(u&1)
+			var s = samples
+			switch order {
+			case 0:
+				s[i] = res
+			case 1:
+				s[i] = res + s[i-1]
+			case 2:
+				s[i] = res + 2*s[i-1] - s[i-2]
+			case 3:
+				s[i] = res + 3*s[i-1] - 3*s[i-2] + s[i-3]
+			case 4:
+				s[i] = res + 4*s[i-1] - 6*s[i-2] + 4*s[i-3] - s[i-4]
+			}
+		}
+	default:
+		panic("unexpected subframe type")
+	}
+	return samples
+}
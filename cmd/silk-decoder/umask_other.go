@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// umask returns 0 where the file mode creation mask is not supported.
+// 不支持 umask 的系统返回 0
+func umask() fs.FileMode { return 0 }
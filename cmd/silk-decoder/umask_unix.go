@@ -0,0 +1,16 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// umask returns the file mode creation mask of the process.
+// 返回进程的 umask
+func umask() fs.FileMode {
+	var mask = syscall.Umask(0) // 只能通过设置来读取, 读取后马上恢复
+	syscall.Umask(mask)
+	return fs.FileMode(mask)
+}
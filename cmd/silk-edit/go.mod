@@ -0,0 +1,25 @@
+module github.com/youthlin/silk/cmd/silk-edit
+
+go 1.20
+
+require (
+	github.com/youthlin/silk v0.0.3
+	github.com/youthlin/t v0.0.7
+)
+
+require (
+	github.com/Xuanwo/go-locale v1.0.0 // indirect
+	github.com/antlr/antlr4/runtime/Go/antlr v0.0.0-20210803070921-b358b509191a // indirect
+	github.com/cockroachdb/errors v1.8.6 // indirect
+	github.com/cockroachdb/logtags v0.0.0-20190617123548-eb05cc24525f // indirect
+	github.com/cockroachdb/redact v1.1.1 // indirect
+	github.com/cockroachdb/sentry-go v0.6.1-cockroachdb.2 // indirect
+	github.com/gogo/protobuf v1.3.2 // indirect
+	github.com/kr/pretty v0.2.1 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/sys v0.0.0-20210616094352-59db8d763f22 // indirect
+	golang.org/x/text v0.3.7 // indirect
+)
+
+replace github.com/youthlin/silk => ../../
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/youthlin/silk"
+	"github.com/youthlin/silk/internal"
+	"github.com/youthlin/t"
+)
+
+//go:embed *.po
+var poFiles embed.FS
+var args = &appArgs{}
+
+type appArgs struct {
+	lang    string
+	input   string
+	output  string
+	from    time.Duration
+	to      time.Duration
+	verbose bool
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		i18n()
+		printUsage()
+		os.Exit(1)
+	}
+	var command = os.Args[1]
+	var flags = parseArgs(command, os.Args[2:])
+	i18n()
+	if args.output == "" {
+		printUsage()
+		fmt.Println(t.T("[Error] output file is required.\n"))
+		os.Exit(1)
+	}
+
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	switch command {
+	case "cut":
+		err = cut(&buf)
+	case "concat":
+		err = concat(&buf, flags.Args())
+	default:
+		printUsage()
+		fmt.Println(t.T("[Error] unknown command: %s\n", command))
+		os.Exit(1)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	if err = os.WriteFile(args.output, buf.Bytes(), 0666); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, t.T("failed to write output file %q: %+v", args.output, err))
+		os.Exit(1)
+	}
+}
+
+func cut(out io.Writer) error {
+	if args.input == "" {
+		return errors.New(t.T("[Error] input file is required."))
+	}
+	input, err := os.Open(args.input)
+	if err != nil {
+		return errors.New(t.T("failed to open input file %q: %+v", args.input, err))
+	}
+	defer input.Close()
+	if err = silk.Cut(input, out, args.from, args.to); err != nil {
+		return errors.New(t.T("failed to cut input file %q: %+v", args.input, err))
+	}
+	return nil
+}
+
+func concat(out io.Writer, inputs []string) error {
+	if args.input != "" {
+		inputs = append([]string{args.input}, inputs...)
+	}
+	if len(inputs) == 0 {
+		return errors.New(t.T("[Error] input file is required."))
+	}
+	var srcs []io.Reader
+	for _, path := range inputs {
+		input, err := os.Open(path)
+		if err != nil {
+			return errors.New(t.T("failed to open input file %q: %+v", path, err))
+		}
+		defer input.Close()
+		srcs = append(srcs, input)
+	}
+	if err := silk.Concat(out, srcs...); err != nil {
+		return errors.New(t.T("failed to concat input files: %+v", err))
+	}
+	return nil
+}
+
+func i18n() {
+	if args.lang == "" {
+		t.LoadFS(poFiles)
+	} else {
+		t.Load(args.lang)
+	}
+}
+
+func parseArgs(command string, arguments []string) *flag.FlagSet {
+	var flags = flag.NewFlagSet(command, flag.ExitOnError)
+	flags.StringVar(&args.lang, "l", "", "")
+	flags.StringVar(&args.input, "i", "", "")
+	flags.StringVar(&args.output, "o", "", "")
+	flags.DurationVar(&args.from, "from", 0, "")
+	flags.DurationVar(&args.to, "to", 0, "")
+	flags.BoolVar(&args.verbose, "verbose", false, "")
+	flags.Usage = printUsage
+	_ = flags.Parse(arguments)
+	internal.Verbose = args.verbose
+	return flags
+}
+
+func printUsage() {
+	fmt.Println()
+	fmt.Println(t.T("Silk editor, cut or concat silk v3 files without re-encoding, by youthlin"))
+	fmt.Println(t.T("GitHub: https://github.comyouthlin/silk"))
+	fmt.Println()
+	fmt.Println(t.T("Usage: %s cut -i <input file> -o <output file> [-from <time>] [-to <time>]", os.Args[0]))
+	fmt.Println(t.T("       %s concat -o <output file> <input file>...", os.Args[0]))
+	fmt.Println(t.T("  [settings]"))
+	fmt.Println(t.T("    -l <path to po file>\tlanguage path(pointer to po file/dir)"))
+	fmt.Println(t.T("    -i <input file>\t\tsilk v3 input file"))
+	fmt.Println(t.T("    -o <output file>\t\toutput file"))
+	fmt.Println(t.T("    -from <time>\t\tstart time, e.g.: 1.5s, 500ms, default: 0"))
+	fmt.Println(t.T("    -to <time>\t\t\tend time, default: the end of input"))
+	fmt.Println(t.T("    -verbose\t\t\tprint verbose log, default false"))
+	fmt.Println()
+	fmt.Println(t.T("Cut and concat are aligned to packets(20ms by default)."))
+	fmt.Println(t.T("The output has STX flag if the (first) input has, and inputs of concat must have the same internal sample rate."))
+	fmt.Println()
+	fmt.Println(t.T("Example:"))
+	var name = os.Args[0]
+	fmt.Println(t.X("cmd-example", "%s cut -i a.amr -o b.amr -from 2s\n\tremove the first 2 seconds of a.amr", name))
+	fmt.Println(t.X("cmd-example", "%s cut -i a.amr -o b.amr -from 1m -to 1m30s\n\tkeep 30 seconds from 1 minute of a.amr", name))
+	fmt.Println(t.X("cmd-example", "%s concat -o c.amr a.amr b.amr\n\tjoin a.amr and b.amr into c.amr", name))
+	fmt.Println()
+}
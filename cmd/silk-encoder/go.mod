@@ -21,3 +21,5 @@ require (
 	golang.org/x/sys v0.0.0-20210616094352-59db8d763f22 // indirect
 	golang.org/x/text v0.3.7 // indirect
 )
+
+replace github.com/youthlin/silk => ../../
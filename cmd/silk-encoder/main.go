@@ -4,7 +4,9 @@ import (
 	"embed"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/youthlin/silk"
 	"github.com/youthlin/silk/internal"
@@ -20,34 +22,81 @@ func main() {
 	i18n()
 	if args.input == "" || args.output == "" {
 		printUsage()
-		fmt.Println(t.T("[Error] both input file and output file are required.\n"))
+		fmt.Fprintln(os.Stderr, t.T("[Error] both input file and output file are required.\n"))
 		os.Exit(1)
 	}
 
-	input, err := os.Open(args.input)
+	input, err := openInput(args.input)
 	if err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, t.T("failed to open input file %q: %+v", args.input, err))
 		os.Exit(1)
 	}
 
-	buf, err := silk.Encode(
-		input,
+	var opts []internal.EncodeOpt
+	if args.Preset != "" {
+		preset, ok := silk.Preset(args.Preset)
+		if !ok {
+			fmt.Fprintln(os.Stderr, t.T("[Error] unknown preset %q, should be one of %v", args.Preset, silk.PresetNames()))
+			os.Exit(1)
+		}
+		opts = append(opts, preset)
+	}
+	// 使用预设时, 只有明确指定的参数才覆盖预设
+	for _, opt := range []struct {
+		flag string
+		opt  internal.EncodeOpt
+	}{
+		{"Fs_maxInternal", silk.MaxInternal(args.FsMaxInternal)},
+		{"packetlength", silk.PacketSizeMs(args.PacketLength)},
+		{"loss", silk.PackageLossPct(args.LossPct)},
+		{"inbandFEC", silk.InbandFEC(args.InbandFEC)},
+		{"DTX", silk.UseDTX(args.DTX)},
+		{"complexity", silk.Complexity(args.Complexity)},
+		{"rate", silk.BitRate(args.Rate)},
+		{"STX", silk.Stx(args.STX)},
+	} {
+		if args.Preset == "" || args.set[opt.flag] {
+			opts = append(opts, opt.opt)
+		}
+	}
+	opts = append(opts,
 		silk.SampleRate(args.FsAPI),
-		silk.MaxInternal(args.FsMaxInternal),
-		silk.PacketSizeMs(args.PacketLength),
-		silk.PackageLossPct(args.LossPct),
-		silk.InbandFEC(args.InbandFEC),
-		silk.UseDTX(args.DTX),
-		silk.Complexity(args.Complexity),
-		silk.BitRate(args.Rate),
-		silk.Stx(args.STX),
+		silk.Ogg(args.Ogg),
+		silk.Channels(args.Channels),
+		silk.SelectChannel(args.Channel),
+		silk.BigEndianPCM(args.BigEndian),
+		silk.FadeOut(args.FadeOut),
+		silk.WriteMetadata(args.Metadata),
 	)
+	if args.Trim > 0 {
+		fmt.Fprintln(os.Stderr, t.T("[Error] invalid -trim %v, should be a negative level in dBFS(e.g. -40)", args.Trim))
+		os.Exit(1)
+	}
+	if args.Trim < 0 {
+		opts = append(opts, silk.TrimSilence(args.Trim, args.TrimPadding))
+	}
+	if args.Normalize != 0 {
+		var modes = map[string]silk.NormalizeMode{
+			"lufs": silk.NormalizeLUFS,
+			"rms":  silk.NormalizeRMS,
+			"peak": silk.NormalizePeak,
+		}
+		mode, ok := modes[args.NormalizeMode]
+		if !ok {
+			fmt.Fprintln(os.Stderr, t.T("[Error] invalid normalize mode %q, should be one of lufs, rms, peak", args.NormalizeMode))
+			os.Exit(1)
+		}
+		opts = append(opts, silk.Normalize(mode, args.Normalize))
+	}
+	var stats silk.EncodeStats
+	opts = append(opts, silk.TargetSize(args.TargetSize), silk.CBR(args.CBR), silk.TwoPass(args.TwoPass), silk.Stats(&stats))
+	buf, err := silk.Encode(input, opts...)
 	if err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, t.T("failed to encode input file %q: %+v", args.input, err))
 		os.Exit(1)
 	}
 
-	output, err := os.OpenFile(args.output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	output, err := openOutput(args.output)
 	if err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, t.T("failed to open output file %q: %+v", args.output, err))
 		os.Exit(1)
@@ -57,6 +106,32 @@ func main() {
 		_, _ = fmt.Fprintln(os.Stderr, t.T("failed to write output file %q: %+v", args.output, err))
 		os.Exit(1)
 	}
+	if args.TargetSize > 0 || args.CBR > 0 || args.TwoPass || args.Verbose {
+		// 输出到 stderr, 不影响写入 stdout 的 silk 数据
+		_, _ = fmt.Fprintln(os.Stderr, t.T("output size: %d bytes, duration: %v, average bitrate: %d bps", stats.Bytes, stats.Duration, stats.BitRate))
+		if args.CBR > 0 && stats.Oversize > 0 {
+			_, _ = fmt.Fprintln(os.Stderr, t.T("%d of %d packets exceed the CBR packet size", stats.Oversize, stats.Packets))
+		}
+	}
+}
+
+// stdio is the file name of stdin/stdout.
+const stdio = "-"
+
+// openInput opens the input file, or stdin when path is "-".
+func openInput(path string) (io.ReadCloser, error) {
+	if path == stdio {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// openOutput creates the output file, or returns stdout when path is "-".
+func openOutput(path string) (*os.File, error) {
+	if path == stdio {
+		return os.Stdout, nil
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
 }
 
 type appArgs struct {
@@ -72,6 +147,21 @@ type appArgs struct {
 	InbandFEC     bool
 	DTX           bool
 	STX           bool
+	Ogg           bool
+	Trim          float64
+	TrimPadding   time.Duration
+	Channels      int
+	Channel       int
+	BigEndian     bool
+	Normalize     float64
+	NormalizeMode string
+	Preset        string
+	TargetSize    int64
+	CBR           int
+	TwoPass       bool
+	FadeOut       bool
+	Metadata      bool
+	set           map[string]bool // 命令行中明确指定的参数
 	Verbose       bool
 }
 
@@ -96,32 +186,62 @@ func parseArgs() {
 	flag.IntVar(&args.Complexity, "complexity", 2, "")
 	flag.BoolVar(&args.DTX, "DTX", false, "")
 	flag.BoolVar(&args.STX, "STX", true, "")
+	flag.BoolVar(&args.Ogg, "ogg", false, "")
+	flag.Float64Var(&args.Trim, "trim", 0, "")
+	flag.DurationVar(&args.TrimPadding, "trimPadding", 200*time.Millisecond, "")
+	flag.IntVar(&args.Channels, "channels", 1, "")
+	flag.IntVar(&args.Channel, "channel", 0, "")
+	flag.BoolVar(&args.BigEndian, "bigEndian", false, "")
+	flag.Float64Var(&args.Normalize, "normalize", 0, "")
+	flag.StringVar(&args.NormalizeMode, "normalizeMode", "lufs", "")
+	flag.StringVar(&args.Preset, "preset", "", "")
+	flag.Int64Var(&args.TargetSize, "targetsize", 0, "")
+	flag.IntVar(&args.CBR, "cbr", 0, "")
+	flag.BoolVar(&args.TwoPass, "twopass", false, "")
+	flag.BoolVar(&args.FadeOut, "fadeout", false, "")
+	flag.BoolVar(&args.Metadata, "metadata", false, "")
 	flag.BoolVar(&args.Verbose, "verbose", false, "")
 	flag.Usage = printUsage
 	flag.Parse()
+	args.set = map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { args.set[f.Name] = true })
 	internal.Verbose = args.Verbose
 }
 
 func printUsage() {
-	fmt.Println()
-	fmt.Println(t.T("Silk encoder, Go version, based on v1.0.9 of C version"))
-	fmt.Println(t.T("Encode pcm file to silk v3 type, by youthlin"))
-	fmt.Println(t.T("GitHub: https://github.comyouthlin/silk"))
-	fmt.Println()
-	fmt.Println(t.T("Usage: %s [settings]", os.Args[0]))
-	fmt.Println(t.T("  [settings]"))
-	fmt.Println(t.T("    -l <path to po file>\tlanguage path(pointer to po file/dir)"))
-	fmt.Println(t.T("    -i <input file>\t\tSpeech input to encoder"))
-	fmt.Println(t.T("    -o <output file>\t\tBitstream output from encoder"))
-	fmt.Println(t.T("    -Fs_API <Hz>\t\tAPI sampling rate in Hz, default: 24000"))
-	fmt.Println(t.T("    -Fs_maxInternal <Hz>\tMaximum internal sampling rate in Hz, default: 24000"))
-	fmt.Println(t.T("    -packetlength <ms>\t\tPacket interval in ms, default: 20"))
-	fmt.Println(t.T("    -rate <bps>\t\t\tTarget bitrate; default: 25000"))
-	fmt.Println(t.T("    -loss <perc>\t\tUplink loss estimate, in percent (0-100); default: 0"))
-	fmt.Println(t.T("    -inbandFEC[=false]\t\tEnable inband FEC usage, default: false"))
-	fmt.Println(t.T("    -complexity <comp>\t\tSet complexity, 0: low, 1: medium, 2: high; default: 2"))
-	fmt.Println(t.T("    -DTX\t\t\tEnable DTX; default: false"))
-	fmt.Println(t.T("    -stx[=false]\t\tAdd STX flag before file header and remove footer block, default true"))
-	fmt.Println(t.T("    -verbose\t\t\tprint verbose log, default false"))
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, t.T("Silk encoder, Go version, based on v1.0.9 of C version"))
+	fmt.Fprintln(os.Stderr, t.T("Encode pcm file to silk v3 type, by youthlin"))
+	fmt.Fprintln(os.Stderr, t.T("GitHub: https://github.comyouthlin/silk"))
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, t.T("Usage: %s [settings]", os.Args[0]))
+	fmt.Fprintln(os.Stderr, t.T("  [settings]"))
+	fmt.Fprintln(os.Stderr, t.T("    -l <path to po file>\tlanguage path(pointer to po file/dir)"))
+	fmt.Fprintln(os.Stderr, t.T("    -i <input file>\t\tSpeech input to encoder, - for stdin"))
+	fmt.Fprintln(os.Stderr, t.T("    -o <output file>\t\tBitstream output from encoder, - for stdout"))
+	fmt.Fprintln(os.Stderr, t.T("    -preset <name>\t\tUse encode preset: wechat, qq, voip-narrowband, high-quality;\n\t\t\t\tthe codec settings given explicitly override the preset"))
+	fmt.Fprintln(os.Stderr, t.T("    -Fs_API <Hz>\t\tAPI sampling rate in Hz, default: 24000"))
+	fmt.Fprintln(os.Stderr, t.T("    -Fs_maxInternal <Hz>\tMaximum internal sampling rate in Hz, default: 24000"))
+	fmt.Fprintln(os.Stderr, t.T("    -packetlength <ms>\t\tPacket interval in ms, default: 20"))
+	fmt.Fprintln(os.Stderr, t.T("    -rate <bps>\t\t\tTarget bitrate; default: 25000"))
+	fmt.Fprintln(os.Stderr, t.T("    -targetsize <bytes>\t\tAdjust the bitrate per frame to keep the output within the size, default: 0(disabled)"))
+	fmt.Fprintln(os.Stderr, t.T("    -cbr <bytes>\t\tConstant bitrate: pad every packet to the size, default: 0(disabled)"))
+	fmt.Fprintln(os.Stderr, t.T("    -twopass\t\t\tTwo-pass encoding: more bitrate for voiced frames, less for unvoiced and silence, default: false"))
+	fmt.Fprintln(os.Stderr, t.T("    -loss <perc>\t\tUplink loss estimate, in percent (0-100); default: 0"))
+	fmt.Fprintln(os.Stderr, t.T("    -inbandFEC[=false]\t\tEnable inband FEC usage, default: false"))
+	fmt.Fprintln(os.Stderr, t.T("    -complexity <comp>\t\tSet complexity, 0: low, 1: medium, 2: high; default: 2"))
+	fmt.Fprintln(os.Stderr, t.T("    -DTX\t\t\tEnable DTX; default: false"))
+	fmt.Fprintln(os.Stderr, t.T("    -stx[=false]\t\tAdd STX flag before file header and remove footer block, default true"))
+	fmt.Fprintln(os.Stderr, t.T("    -ogg[=false]\t\tOutput as Ogg stream(-stx is ignored), default false"))
+	fmt.Fprintln(os.Stderr, t.T("    -metadata[=false]\t\tWrite original length and creation time after the footer(ignored with -stx),\n\t\t\t\tthe decoder strips the padding by it, default false"))
+	fmt.Fprintln(os.Stderr, t.T("    -channels <n>\t\tNumber of interleaved channels of input, downmixed to mono; default: 1"))
+	fmt.Fprintln(os.Stderr, t.T("    -channel <ch>\t\tKeep only the channel(starting from 1) of multi-channel input, default: 0(average all channels)"))
+	fmt.Fprintln(os.Stderr, t.T("    -bigEndian\t\t\tInput pcm is big endian, default: false"))
+	fmt.Fprintln(os.Stderr, t.T("    -fadeout\t\t\tFade out the last partial frame(less than 20ms) before zero padding it, default: false"))
+	fmt.Fprintln(os.Stderr, t.T("    -trim <dB>\t\t\tTrim leading and trailing silence below the level(dBFS, e.g. -40), default: 0(disabled)"))
+	fmt.Fprintln(os.Stderr, t.T("    -trimPadding <time>\t\tSilence kept around the sound when trimming, default: 200ms"))
+	fmt.Fprintln(os.Stderr, t.T("    -normalize <level>\t\tNormalize loudness to the level(e.g. -16) before encoding, default: 0(disabled)"))
+	fmt.Fprintln(os.Stderr, t.T("    -normalizeMode <mode>\tLoudness measurement: lufs(EBU R128, with -1 dBTP true peak ceiling), rms or peak(dBFS), default: lufs"))
+	fmt.Fprintln(os.Stderr, t.T("    -verbose\t\t\tprint verbose log, default false"))
+	fmt.Fprintln(os.Stderr)
 }
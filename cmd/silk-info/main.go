@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/youthlin/silk"
+	"github.com/youthlin/silk/internal"
+	"github.com/youthlin/t"
+)
+
+//go:embed *.po
+var poFiles embed.FS
+var args = &appArgs{}
+
+type appArgs struct {
+	lang    string
+	Verbose bool
+}
+
+func main() {
+	parseArgs()
+	i18n()
+	if flag.NArg() == 0 {
+		printUsage()
+		fmt.Println(t.T("[Error] input file is required.\n"))
+		os.Exit(1)
+	}
+	var failed bool
+	for i, name := range flag.Args() {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := printInfo(name); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, t.T("failed to read file %q: %+v", name, err))
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// printInfo prints the format, duration, bitrate, metadata and tags of the file.
+func printInfo(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	format, err := silk.DetectFormat(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	fmt.Println(name)
+	fmt.Println(t.T("  format:\t%v", format))
+	fmt.Println(t.T("  size:\t\t%d bytes", len(data)))
+	if format != silk.FormatSilk && format != silk.FormatSilkStx {
+		return nil // 其他格式只显示格式和大小
+	}
+
+	index, err := silk.BuildIndex(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	var duration = time.Duration(index.Samples * int64(time.Second) / int64(index.SampleRate))
+	fmt.Println(t.T("  duration:\t%v", duration))
+	fmt.Println(t.T("  packets:\t%d", len(index.Packets)))
+	if duration > 0 {
+		fmt.Println(t.T("  bitrate:\t%d bps", int64(len(data))*8*int64(time.Second)/int64(duration)))
+	}
+
+	meta, err := silk.ReadMetadata(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if meta != nil {
+		fmt.Println(t.T("  original:\t%v(%d samples at %d Hz)", meta.Duration(), meta.Samples, meta.SampleRate))
+		fmt.Println(t.T("  created:\t%v", meta.Created.Format(time.RFC3339)))
+	}
+
+	tags, err := silk.ReadTags(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	var keys = make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Println(t.T("  tags:"))
+	for _, k := range keys {
+		fmt.Printf("    %s: %q\n", k, tags[k])
+	}
+	return nil
+}
+
+func i18n() {
+	if args.lang == "" {
+		t.LoadFS(poFiles)
+	} else {
+		t.Load(args.lang)
+	}
+}
+
+func parseArgs() {
+	flag.StringVar(&args.lang, "l", "", "")
+	flag.BoolVar(&args.Verbose, "verbose", false, "")
+	flag.Usage = printUsage
+	flag.Parse()
+	internal.Verbose = args.Verbose
+}
+
+func printUsage() {
+	fmt.Println()
+	fmt.Println(t.T("Silk info, show the format, duration, metadata and tags of silk v3 files, by youthlin"))
+	fmt.Println(t.T("GitHub: https://github.comyouthlin/silk"))
+	fmt.Println()
+	fmt.Println(t.T("Usage: %s [settings] <file>...", os.Args[0]))
+	fmt.Println(t.T("  [settings]"))
+	fmt.Println(t.T("    -l <path to po file>\tlanguage path(pointer to po file/dir)"))
+	fmt.Println(t.T("    -verbose\t\t\tprint verbose log, default false"))
+	fmt.Println()
+	fmt.Println(t.T("Example:"))
+	var name = os.Args[0]
+	fmt.Println(t.X("cmd-example", "%s a.amr b.amr\n\tshow the information of a.amr and b.amr", name))
+	fmt.Println()
+}
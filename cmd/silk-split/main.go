@@ -0,0 +1,136 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/youthlin/silk"
+	"github.com/youthlin/silk/internal"
+	"github.com/youthlin/t"
+)
+
+//go:embed *.po
+var poFiles embed.FS
+var args = &appArgs{}
+
+type appArgs struct {
+	lang        string
+	input       string
+	output      string
+	maxDuration time.Duration
+	minSilence  time.Duration
+	FsAPI       int
+	Rate        int
+	STX         bool
+	Verbose     bool
+}
+
+func main() {
+	parseArgs()
+	i18n()
+	if args.input == "" {
+		printUsage()
+		fmt.Println(t.T("[Error] input file is required.\n"))
+		os.Exit(1)
+	}
+
+	input, err := os.Open(args.input)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, t.T("failed to open input file %q: %+v", args.input, err))
+		os.Exit(1)
+	}
+	defer input.Close()
+
+	segments, err := silk.Split(
+		input,
+		silk.WithMaxDuration(args.maxDuration),
+		silk.WithMinSilence(args.minSilence),
+		silk.WithEncodeOpts(
+			silk.SampleRate(args.FsAPI),
+			silk.BitRate(args.Rate),
+			silk.Stx(args.STX),
+		),
+	)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, t.T("failed to split input file %q: %+v", args.input, err))
+		os.Exit(1)
+	}
+
+	var pattern = outputPattern()
+	for i, seg := range segments {
+		var name = fmt.Sprintf(pattern, i+1)
+		if err = os.WriteFile(name, seg, 0666); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, t.T("failed to write output file %q: %+v", name, err))
+			os.Exit(1)
+		}
+		fmt.Println(name)
+	}
+}
+
+// outputPattern returns the file name pattern of segments, which contains a %d verb for the segment number.
+func outputPattern() string {
+	var name = args.output
+	if name == "" {
+		name = args.input
+		if ext := filepath.Ext(name); ext == "" || strings.EqualFold(ext, ".pcm") {
+			name = strings.TrimSuffix(name, ext) + ".amr"
+		}
+	}
+	if strings.Contains(name, "%") {
+		return name
+	}
+	var ext = filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "_%03d" + ext
+}
+
+func i18n() {
+	if args.lang == "" {
+		t.LoadFS(poFiles)
+	} else {
+		t.Load(args.lang)
+	}
+}
+
+func parseArgs() {
+	flag.StringVar(&args.lang, "l", "", "")
+	flag.StringVar(&args.input, "i", "", "")
+	flag.StringVar(&args.output, "o", "", "")
+	flag.DurationVar(&args.maxDuration, "max", 60*time.Second, "")
+	flag.DurationVar(&args.minSilence, "silence", 300*time.Millisecond, "")
+	flag.IntVar(&args.FsAPI, "Fs_API", 24000, "")
+	flag.IntVar(&args.Rate, "rate", 25000, "")
+	flag.BoolVar(&args.STX, "stx", true, "")
+	flag.BoolVar(&args.Verbose, "verbose", false, "")
+	flag.Usage = printUsage
+	flag.Parse()
+	internal.Verbose = args.Verbose
+}
+
+func printUsage() {
+	fmt.Println()
+	fmt.Println(t.T("Silk splitter, split a long recording into silk v3 files on silence, by youthlin"))
+	fmt.Println(t.T("GitHub: https://github.comyouthlin/silk"))
+	fmt.Println()
+	fmt.Println(t.T("Usage: %s -i <input file> [settings]", os.Args[0]))
+	fmt.Println(t.T("  -i <input file>\t\tsilk v3 file, or pcm file(16bit little endian)"))
+	fmt.Println(t.T("  [settings]"))
+	fmt.Println(t.T("    -l <path to po file>\tlanguage path(pointer to po file/dir)"))
+	fmt.Println(t.T("    -o <pattern>\t\toutput file name pattern, default: %s", "<input>_%03d.amr"))
+	fmt.Println(t.T("    -max <time>\t\t\tmax duration of each file, default: 60s"))
+	fmt.Println(t.T("    -silence <time>\t\tmin duration of a pause to split at, default: 300ms"))
+	fmt.Println(t.T("    -Fs_API <Hz>\t\tsampling rate of pcm input in Hz, default: 24000"))
+	fmt.Println(t.T("    -rate <bps>\t\t\ttarget bitrate for pcm input; default: 25000"))
+	fmt.Println(t.T("    -stx[=false]\t\tAdd STX flag before file header and remove footer block, default true"))
+	fmt.Println(t.T("    -verbose\t\t\tprint verbose log, default false"))
+	fmt.Println()
+	fmt.Println(t.T("Example:"))
+	var name = os.Args[0]
+	fmt.Println(t.X("cmd-example", "%s -i meeting.pcm\n\tsplit meeting.pcm to meeting_001.amr, meeting_002.amr...", name))
+	fmt.Println(t.X("cmd-example", "%s -i a.amr -max 30s -o %s\n\tsplit a.amr to part1.amr, part2.amr... at most 30 seconds each", name, "part%d.amr"))
+	fmt.Println()
+}
@@ -0,0 +1,32 @@
+package silk
+
+import (
+	"io"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// Decoder decodes a silk stream to pcm incrementally, it implements io.Reader.
+// When the source is an io.ReadSeeker, SeekSample/SeekTime can jump to any position.
+// 流式解码器, 源数据实现了 io.ReadSeeker 时可以跳转
+type Decoder = internal.Decoder
+
+// NewDecoder creates a Decoder, the Decoder should be closed after use.
+// 创建流式解码器, 使用完毕后需要 Close
+func NewDecoder(src io.Reader, opts ...internal.DecodeOpt) (*Decoder, error) {
+	return internal.NewDecoder(src, opts...)
+}
+
+// Index records byte offset and sample position of every packet, it can be cached by MarshalBinary.
+// 数据包索引, 可以通过 MarshalBinary 序列化后缓存
+type Index = internal.Index
+
+// IndexEntry is the position of a packet in Index.
+// 数据包的位置
+type IndexEntry = internal.IndexEntry
+
+// BuildIndex reads the silk stream once and builds the packet index, use Decoder.SetIndex to seek with it.
+// 读取一遍数据流，建立数据包索引, 通过 Decoder.SetIndex 使用
+func BuildIndex(src io.Reader, opts ...internal.DecodeOpt) (*Index, error) {
+	return internal.BuildIndex(src, opts...)
+}
@@ -2,6 +2,7 @@ package silk
 
 import (
 	"io"
+	"time"
 
 	"github.com/youthlin/silk/internal"
 )
@@ -9,7 +10,9 @@ import (
 // -------------------- Decode --------------------
 
 // Decode decodes silk encode src to pcm.
-// 解码 silk 格式为 pcm 格式.
+// The input can be silk v3 file(with or without STX), Ogg/silk or WAV/silk,
+// other formats get an error wrapping ErrUnsupportedFormat.
+// 解码 silk 格式为 pcm 格式. 支持 silk v3(可以有 STX 前缀)、Ogg/silk、WAV/silk, 其他格式返回 ErrUnsupportedFormat 错误.
 func Decode(src io.Reader, opts ...internal.DecodeOpt) ([]byte, error) {
 	return internal.Decode(src, opts...)
 }
@@ -20,10 +23,25 @@ func WithSampleRate(sampleRate int) internal.DecodeOpt {
 	return func(dc *internal.DecodeCfg) { dc.SampleRate = sampleRate }
 }
 
+// WithOriginalLength trims the decoded output to the original length of the input of Encode,
+// samples is counted at sampleRate(0 means the decode sample rate), see EncodeStats.Samples.
+// 将解码结果截断为编码前输入的长度, 去掉末尾的填充; samples 是 sampleRate 采样率下的采样点数(sampleRate 为 0 表示与解码采样率相同)
+func WithOriginalLength(samples int64, sampleRate int) internal.DecodeOpt {
+	return func(dc *internal.DecodeCfg) {
+		dc.OriginalSamples = samples
+		dc.OriginalSampleRate = sampleRate
+	}
+}
+
 // -------------------- Encode --------------------
 
 // Encode encode pcm file to silk v3 type.
+// The input is encoded in 20ms frames, the last partial frame is zero padded(see FadeOut),
+// and the last packet is filled with silent frames when PacketSizeMs > 20,
+// so the decoded output can be longer than the input, see WithOriginalLength to strip the padding.
 // 将 pcm 格式编码为 silk v3 格式.
+// 输入按 20ms 一帧编码, 最后不足一帧的部分补零, PacketSizeMs > 20 时最后一个数据包用静音帧填满,
+// 因此解码结果可能比输入长, 可以通过 WithOriginalLength 去掉末尾的填充
 func Encode(src io.Reader, opts ...internal.EncodeOpt) ([]byte, error) {
 	return internal.Encode(src, opts...)
 }
@@ -69,8 +87,8 @@ func Complexity(mode int) internal.EncodeOpt {
 	return func(ec *internal.EncodeCfg) { ec.ComplexityMode = mode }
 }
 
-// BitRate set target bitrate; default: 25000
-// 设置比特率，默认值 25000
+// BitRate set target bitrate; default: 25000, 0 means the SDK default(the minimum bitrate)
+// 设置比特率，默认值 25000, 0 表示使用 SDK 的默认值(最低码率)
 func BitRate(bitRate int) internal.EncodeOpt {
 	return func(ec *internal.EncodeCfg) { ec.BitRate = bitRate }
 }
@@ -80,3 +98,34 @@ func BitRate(bitRate int) internal.EncodeOpt {
 func Stx(enable bool) internal.EncodeOpt {
 	return func(ec *internal.EncodeCfg) { ec.Stx = enable }
 }
+
+// Ogg set output format to Ogg stream instead of silk v3 file, the Stx setting is ignored.
+// Decode accepts Ogg stream transparently.
+// 输出为 Ogg 封装格式(此时忽略 Stx 设置), 解码时会自动识别 Ogg 格式
+func Ogg(enable bool) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.Ogg = enable }
+}
+
+// TrimSilence drops leading and trailing silence before encoding:
+// 20ms frames whose RMS level is below thresholdDb(dBFS, at most 0, e.g. -40) are silent,
+// and padding of silence is kept before and after the sound.
+// 编码前去掉开头和结尾的静音: RMS 电平低于 thresholdDb(dBFS, 不大于 0, 如 -40) 的帧认为是静音, 声音前后保留 padding 时长的静音
+func TrimSilence(thresholdDb float64, padding time.Duration) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) {
+		ec.TrimSilence = true
+		ec.TrimThresholdDb = thresholdDb
+		ec.TrimPadding = padding
+	}
+}
+
+// FadeOut fades out the last partial frame of the input before zero padding it, to avoid a click at the end.
+// 编码前将最后不足一帧的输入淡出后再补零, 避免结尾的爆音
+func FadeOut(enable bool) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.FadeOut = enable }
+}
+
+// Version returns the version of the silk SDK(C version).
+// 返回 C 语言版本 SDK 的版本号
+func Version() string {
+	return internal.Version()
+}
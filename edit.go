@@ -0,0 +1,22 @@
+package silk
+
+import (
+	"io"
+	"time"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// Cut copies the packets between from and to of the silk file src to dst without re-encoding,
+// to <= 0 means the end of src. The STX flag of src is kept.
+// 按数据包剪切 silk 文件(不重新编码), to <= 0 表示直到文件结尾, 保持输入是否有 STX 标记
+func Cut(src io.Reader, dst io.Writer, from, to time.Duration) error {
+	return internal.Cut(src, dst, from, to)
+}
+
+// Concat joins the silk files srcs into dst without re-encoding, the output follows the STX flag of the first input.
+// Inputs with different internal sampling rates are rejected.
+// 拼接多个 silk 文件(不重新编码), 输出格式与第一个文件一致, 内部采样率不同时返回错误
+func Concat(dst io.Writer, srcs ...io.Reader) error {
+	return internal.Concat(dst, srcs...)
+}
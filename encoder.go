@@ -0,0 +1,26 @@
+package silk
+
+import (
+	"io"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// Encoder encodes pcm to silk incrementally, it implements io.WriteCloser.
+// SetBitRate/SetPacketLoss/SetComplexity/SetFEC change the parameters during encoding, from the next frame.
+// 流式编码器, 编码过程中可以通过 SetBitRate 等方法调整参数, 从下一帧开始生效
+type Encoder = internal.Encoder
+
+// EncodeFeedback is passed to the EncodeController after each frame is encoded.
+// 每编码一帧后传给 EncodeController 的信息
+type EncodeFeedback = internal.EncodeFeedback
+
+// EncodeController is called after each frame is encoded, a congestion controller can adjust the encoder in it.
+// 每编码一帧后调用, 拥塞控制等可以在其中调整编码参数
+type EncodeController = internal.EncodeController
+
+// NewEncoder creates an Encoder writing to dst, the Encoder must be closed to finish the output.
+// 创建流式编码器, 结束时需要 Close
+func NewEncoder(dst io.Writer, opts ...internal.EncodeOpt) (*Encoder, error) {
+	return internal.NewEncoder(dst, opts...)
+}
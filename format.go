@@ -0,0 +1,46 @@
+package silk
+
+import (
+	"errors"
+	"io"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// Format is the format of an input stream detected by DetectFormat.
+// 输入数据的格式
+type Format = internal.Format
+
+const (
+	FormatUnknown = internal.FormatUnknown // 无法识别
+	FormatSilk    = internal.FormatSilk    // #!SILK_V3
+	FormatSilkStx = internal.FormatSilkStx // 0x02 + #!SILK_V3, QQ/WeChat 使用的格式
+	FormatSilkRaw = internal.FormatSilkRaw // 没有文件头的 silk 数据块
+	FormatOggSilk = internal.FormatOggSilk // Ogg 封装的 silk
+	FormatOgg     = internal.FormatOgg     // 其他 Ogg 格式(opus, vorbis 等)
+	FormatWAVSilk = internal.FormatWAVSilk // WAV 文件的 data 块中是 silk
+	FormatWAV     = internal.FormatWAV     // WAV 文件
+	FormatAMR     = internal.FormatAMR     // AMR-NB #!AMR\n
+	FormatAMRWB   = internal.FormatAMRWB   // AMR-WB #!AMR-WB\n
+	FormatMP3     = internal.FormatMP3     // MP3 文件
+	FormatPCM     = internal.FormatPCM     // 看起来像是 16bit 的 pcm 数据
+)
+
+// ErrUnsupportedFormat is returned by Decode when the input is not a decodable silk stream,
+// use errors.As with *FormatError to get the detected format.
+// 输入不是可以解码的 silk 格式时返回此错误, 可以用 errors.As 获取 *FormatError 得到识别出的格式
+var ErrUnsupportedFormat = internal.ErrUnsupportedFormat
+
+// FormatError is the error with the detected format of the input.
+type FormatError = internal.FormatError
+
+// DetectFormat reads the first bytes(at most 4096 bytes) of src and detects its format.
+// 读取开头的数据(最多 4096 字节)并识别格式
+func DetectFormat(src io.Reader) (Format, error) {
+	var head = make([]byte, 4096)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return FormatUnknown, err
+	}
+	return internal.DetectFormat(head[:n]), nil
+}
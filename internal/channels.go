@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// silk 只支持单声道: 编码前将多声道(交错排列的 16 位)输入混合为单声道, 解码后可以复制为多声道输出.
+
+// downmixer converts interleaved multi-channel 16bit pcm to mono,
+// by averaging all channels, or keeping only one channel when channel >= 0.
+// 将交错排列的多声道 pcm 转为单声道: 取所有声道的平均值, 或 channel >= 0 时只保留该声道
+type downmixer struct {
+	reader   io.Reader
+	channels int
+	channel  int
+	in       []byte
+	eof      bool
+}
+
+func newDownmixer(reader io.Reader, channels, channel int) (*downmixer, error) {
+	if channel >= channels || channel < -1 {
+		return nil, fmt.Errorf("invalid channel %d, the input has %d channels", channel+1, channels)
+	}
+	return &downmixer{reader: reader, channels: channels, channel: channel}, nil
+}
+
+func (d *downmixer) Read(p []byte) (int, error) {
+	if d.eof {
+		return 0, io.EOF
+	}
+	var samples = len(p) / 2 // 输出的采样数
+	if samples == 0 {
+		return 0, nil
+	}
+	if size := samples * d.channels * 2; cap(d.in) < size {
+		d.in = make([]byte, size)
+	}
+	var in = d.in[:samples*d.channels*2]
+	n, err := io.ReadFull(d.reader, in)
+	if err != nil {
+		if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, fmt.Errorf("failed to read pcm: %w", err)
+		}
+		d.eof = true // 最后不足一个采样点(所有声道)的数据被丢弃
+	}
+	n = d.mix(in[:n], p)
+	if n == 0 && d.eof {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// mix converts the complete samples of in to mono p, and returns the number of bytes written to p.
+func (d *downmixer) mix(in, p []byte) int {
+	var samples = len(in) / 2 / d.channels
+	for i := 0; i < samples; i++ {
+		var frame = in[i*d.channels*2:]
+		var s int
+		if d.channel >= 0 {
+			s = int(readInt16(frame[d.channel*2:]))
+		} else {
+			for ch := 0; ch < d.channels; ch++ {
+				s += int(readInt16(frame[ch*2:]))
+			}
+			s /= d.channels
+		}
+		p[i*2] = byte(s)
+		p[i*2+1] = byte(s >> 8)
+	}
+	return samples * 2
+}
+
+func readInt16(b []byte) int16 {
+	return int16(uint16(b[0]) | uint16(b[1])<<8)
+}
+
+// upmix duplicates each sample of mono 16bit pcm to channels.
+// 将单声道 pcm 的每个采样点复制到多个声道
+func upmix(pcm []byte, channels int) []byte {
+	if channels <= 1 {
+		return pcm
+	}
+	var out = make([]byte, 0, len(pcm)*channels)
+	for i := 0; i+1 < len(pcm); i += 2 {
+		for ch := 0; ch < channels; ch++ {
+			out = append(out, pcm[i], pcm[i+1])
+		}
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestDownmix(t *testing.T) {
+	var stereo []byte
+	for _, s := range []int16{100, 300, -100, -300, 7, 8, 1} { // 最后一个不完整的采样点被丢弃
+		stereo = binary.LittleEndian.AppendUint16(stereo, uint16(s))
+	}
+	for _, tt := range []struct {
+		channel int
+		want    []int16
+	}{
+		{-1, []int16{200, -200, 7}},
+		{0, []int16{100, -100, 7}},
+		{1, []int16{300, -300, 8}},
+	} {
+		mixer, err := newDownmixer(bytes.NewReader(stereo), 2, tt.channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(mixer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []byte
+		for _, s := range tt.want {
+			want = binary.LittleEndian.AppendUint16(want, uint16(s))
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("channel=%d: got %v, want %v", tt.channel, got, want)
+		}
+	}
+	if _, err := newDownmixer(nil, 2, 2); err == nil {
+		t.Errorf("expected error for invalid channel")
+	}
+}
+
+func TestChannels(t *testing.T) {
+	var (
+		mono   = sinePCM(defaultSampleRate, 1, 0.3)
+		stereo = upmix(mono, 2)
+	)
+	if len(stereo) != len(mono)*2 || !bytes.Equal(stereo[:4], []byte{mono[0], mono[1], mono[0], mono[1]}) {
+		t.Fatalf("unexpected upmix result")
+	}
+	silkStereo, err := Encode(bytes.NewReader(stereo), func(ec *EncodeCfg) { ec.Channels = 2 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	silkMono, err := Encode(bytes.NewReader(mono))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(silkStereo, silkMono) {
+		t.Errorf("stereo input with identical channels should encode the same as mono")
+	}
+
+	pcm, err := Decode(bytes.NewReader(silkMono), func(dc *DecodeCfg) { dc.Channels = 2 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDecoder(bytes.NewReader(silkMono), func(dc *DecodeCfg) { dc.Channels = 2 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	var buf = make([]byte, 3) // 读取不完整的采样点
+	var streamed []byte
+	for {
+		n, err := d.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		streamed = append(streamed, buf[:n]...)
+	}
+	if !bytes.Equal(streamed, pcm) || len(pcm) != len(mono)*2 {
+		t.Errorf("streaming decode: got %d bytes, decode: %d bytes, want %d", len(streamed), len(pcm), len(mono)*2)
+	}
+	if d.Position() != int64(len(mono)/2) {
+		t.Errorf("position=%d, want %d", d.Position(), len(mono)/2)
+	}
+}
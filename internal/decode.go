@@ -14,10 +14,10 @@ import "C"
 import (
 	"bufio"
 	"bytes"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"unsafe"
 )
@@ -28,26 +28,41 @@ const (
 	HeaderLen      = len(Header) // 文件头长度 = 9
 	// 默认值
 	defaultSampleRate = 24000
+	// 解码时数据块的最大长度, Decoder.c 中 MAX_BYTES_PER_FRAME 是 1024, 和 Encoder.c 不一样;
+	// 超过 1024 字节的包 SDK 按丢包处理, 超过这个长度只可能是损坏的长度前缀
+	maxDecodeBlockSize = 1024 * MAX_INPUT_FRAMES
 )
 
 type DecodeCfg struct {
-	SampleRate int
+	SampleRate         int
+	PayloadType        int           // RTP payload type, only used when decoding pcap file, -1 means any
+	NoHeader           bool          // the stream has no #!SILK_V3 file header, only length-prefixed blocks
+	LengthSize         int           // size of the block length prefix in bytes: 1, 2 or 4, 0 means 2
+	LengthBigEndian    bool          // the block length prefix is big endian
+	PacketLengths      []int         // the stream is bare packets without length prefix, and these are their lengths
+	Normalize          *NormalizeCfg // normalize the loudness of output pcm, nil means disabled
+	Gain               float64       // constant gain in dB applied to output pcm(e.g. measured by MeasureNormalizeGain), 0 means none
+	Channels           int           // duplicate the mono output to channels(interleaved), 0 or 1 means mono
+	PCMBigEndian       bool          // output big endian pcm
+	OriginalSamples    int64         // trim the output to the original length(in samples) of the input of Encode, 0 means no trimming
+	OriginalSampleRate int           // sample rate of OriginalSamples, 0 means SampleRate
 }
 
 type DecodeOpt func(*DecodeCfg)
 
 func Decode(src io.Reader, opts ...DecodeOpt) ([]byte, error) {
 	/* set option */
-	var cfg = &DecodeCfg{SampleRate: defaultSampleRate}
-	for _, opt := range opts {
-		opt(cfg)
+	var cfg = buildDecodeCfg(opts...)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 	log("decode option: %#v", cfg)
 
 	var reader = bufio.NewReader(src)
 
 	/* Check Silk header */
-	if err := checkHeader(reader); err != nil {
+	packets, err := openPackets(reader, cfg)
+	if err != nil {
 		return nil, err
 	}
 
@@ -61,10 +76,104 @@ func Decode(src io.Reader, opts ...DecodeOpt) ([]byte, error) {
 
 	out := &bytes.Buffer{}
 	/* decode */
-	if err := doDecode(reader, psDec, cfg.SampleRate, out); err != nil {
+	if err := doDecode(packets, psDec, cfg.SampleRate, out); err != nil {
 		return nil, err
 	}
-	return out.Bytes(), nil
+	cfg.applyMetadata(packets)
+	return cfg.postProcess(out.Bytes()), nil
+}
+
+// postProcess converts the decoded pcm to the output format: normalization, channels and byte order.
+// 解码后处理: 响度标准化、增益、声道、字节序
+func (cfg *DecodeCfg) postProcess(pcm []byte) []byte {
+	if limit := cfg.outputSamples(); limit >= 0 && int64(len(pcm)) > limit*2 {
+		pcm = pcm[:limit*2] // 去掉编码时末尾的填充
+	}
+	if cfg.Normalize != nil {
+		normalizePCM(pcm, cfg.SampleRate, cfg.Normalize)
+	}
+	if cfg.Gain != 0 {
+		applyGain(pcm, pcmToFloat(pcm), math.Pow(10, cfg.Gain/20))
+	}
+	pcm = upmix(pcm, cfg.Channels)
+	if cfg.PCMBigEndian {
+		swapBytes(pcm)
+	}
+	return pcm
+}
+
+// outputSamples returns the original length at the decode sample rate, -1 means unknown.
+func (cfg *DecodeCfg) outputSamples() int64 {
+	if cfg.OriginalSamples <= 0 {
+		return -1
+	}
+	var rate = int64(cfg.OriginalSampleRate)
+	if rate <= 0 {
+		rate = int64(cfg.SampleRate)
+	}
+	return (cfg.OriginalSamples*int64(cfg.SampleRate) + rate/2) / rate
+}
+
+func buildDecodeCfg(opts ...DecodeOpt) *DecodeCfg {
+	var cfg = &DecodeCfg{
+		SampleRate:  defaultSampleRate,
+		PayloadType: -1,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// openPackets detects the format of the input, and returns the packet reader of it.
+// 识别输入的格式，返回对应的数据包读取器
+func openPackets(reader *bufio.Reader, cfg *DecodeCfg) (packetReader, error) {
+	if cfg.PacketLengths != nil {
+		log("bare packets, count=%d", len(cfg.PacketLengths))
+		return &bareReader{reader: reader, lengths: cfg.PacketLengths}, nil
+	}
+	prefix, err := newLengthPrefix(cfg.LengthSize, cfg.LengthBigEndian)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.NoHeader {
+		log("no file header, length prefix=%d bytes", prefix.size)
+		return &silkReader{reader: reader, prefix: prefix}, nil
+	}
+
+	head, err := reader.Peek(sniffLen) // 数据不足 sniffLen 时也会返回已有的数据
+	if len(head) == 0 {
+		warn("io error / failed to peek file header: %+v", err)
+		return nil, fmt.Errorf("failed to peek file header: %w", err)
+	}
+	var format = DetectFormat(head)
+	log("input format: %s", format)
+	switch format {
+	case FormatSilk, FormatSilkStx:
+		if err = checkHeader(reader); err != nil {
+			return nil, err
+		}
+		return &silkReader{reader: reader, prefix: prefix}, nil
+	case FormatOggSilk:
+		ogg, err := newOggReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return ogg, nil
+	case FormatWAVSilk:
+		// 跳过 WAV 文件头, data 块的内容就是 silk 文件
+		var offset, size, _ = wavDataChunk(head)
+		if _, err = reader.Discard(offset); err != nil {
+			return nil, fmt.Errorf("failed to skip wav header: %w", err)
+		}
+		var data = bufio.NewReader(io.LimitReader(reader, int64(size)))
+		if err = checkHeader(data); err != nil {
+			return nil, err
+		}
+		return &silkReader{reader: data, prefix: prefix}, nil
+	}
+	warn("unsupported format: %s", format)
+	return nil, newFormatError(format, head)
 }
 
 func checkHeader(reader *bufio.Reader) error {
@@ -154,92 +263,228 @@ func initDecoder(psDec unsafe.Pointer) {
 	C.SKP_Silk_SDK_InitDecoder(psDec)
 }
 
-func doDecode(reader io.Reader, psDec unsafe.Pointer, sampleRate int, out io.Writer) (err error) {
+// packetReader reads silk packets one by one, returns io.EOF when there is no more packet.
+// A packet with zero length means it is lost(the same as the reference Decoder.c).
+// 按顺序读取 silk 数据包，长度为 0 的包表示丢包
+type packetReader interface {
+	ReadPacket() ([]byte, error)
+}
+
+// silkReader reads the length-prefixed packets after the file header.
+// 读取文件头之后按 [长度][内容] 排列的数据块
+type silkReader struct {
+	reader     io.Reader
+	prefix     lengthPrefix // 零值为 2 字节小端序
+	blockIndex int          // for debug log
+	tail       trailer      // footer 之后的扩展块
+}
+
+func (r *silkReader) trailer() *trailer { return &r.tail }
+
+func (r *silkReader) ReadPacket() ([]byte, error) {
+	r.blockIndex++
+	// 参考格式说明
+	// https://wufengxue.github.io/2019/04/17/wechat-voice-codec-amr.html
+	// 文件头之后，就是每个 block, 先是 16 字节的 block 大小 n，然后是 n 个字节内容
+	// 最后是 footer 部分，内容是 0xffff, 也可以看做是一个 block(大小是 -1，没有内容)
+
+	nByte, err := r.prefix.read(r.reader) // 先读取 block 大小, 默认占两个字节，按 int16 解析
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			log("packet=%d, EOF when read block size", r.blockIndex)
+			return nil, io.EOF
+		}
+		log("packet=%d, read block size err=%+v", r.blockIndex, err)
+		return nil, fmt.Errorf("failed to read block size: %w", err)
+	}
+	log("packet=%d, block size=%d", r.blockIndex, nByte)
+	if nByte < 0 {
+		// 是 footer 部分, 没有 block 内容, 之后可能有扩展块
+		if !r.tail.read {
+			r.tail.parse(r.reader)
+		}
+		return nil, io.EOF
+	}
+	if nByte > maxDecodeBlockSize { // 损坏的长度前缀, 避免分配过大的内存
+		log("packet=%d, invalid block size=%d", r.blockIndex, nByte)
+		return nil, fmt.Errorf("invalid block size: %d", nByte)
+	}
+
+	// 再读取 block 内容，长度就是 nByte
+	// 解码时会预读后面几个包(查找丢包的冗余数据), 所以每个包都需要单独的 slice
+	var in = make([]byte, nByte)
+	n, err := io.ReadFull(r.reader, in)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			log("packet=%d, EOF when read block data", r.blockIndex)
+			return nil, io.EOF
+		}
+		warn("packet=%d, read block data err=%+v", r.blockIndex, err)
+		return nil, fmt.Errorf("failed to read block: %w", err)
+	}
+	if n != nByte {
+		log("packet=%d, read block data invalid, read %d bytes, expected %d", r.blockIndex, n, nByte)
+		return nil, fmt.Errorf("invalid block")
+	}
+	return in, nil
+}
+
+func doDecode(packets packetReader, psDec unsafe.Pointer, sampleRate int, out io.Writer) (err error) {
 	var (
-		blockIndex int // for debug log
-		decControl C.SKP_SILK_SDK_DecControlStruct
-		// in 对应 C 源码中 payload(SKP_uint8 数组), buf 对应 out(SKP_int16 数组)
-		in = make([]byte, 1024) // Decoder.c 中 MAX_BYTES_PER_FRAME 和 Encoder.c 不一样哦
+		packetIndex int // for debug log
+		decControl  C.SKP_SILK_SDK_DecControlStruct
 		// 20ms FRAME_LENGTH_MS=20 MAX_API_FS_KHZ=48
 		frameSize = (FRAME_LENGTH_MS * MAX_API_FS_KHZ) << 1
 		// frameSize 个 SKP_int16，这里是 []byte 所以 *2
-		buf = make([]byte, frameSize*2) // 相当于 [frameSize]int16 大小
+		buf   = make([]byte, frameSize*2) // 相当于 [frameSize]int16 大小
+		queue = &lbrrQueue{packets: packets}
 	)
 	decControl.API_sampleRate = C.SKP_int32(sampleRate)
 	decControl.framesPerPacket = C.SKP_int(1)
 
 	// https://github.com/kn007/silk-v3-decoder/blob/master/silk/test/Decoder.c
 	// https://github.com/gaozehua/SILKCodec/blob/master/SILK_SDK_SRC_ARM/test/Decoder.c
-	// C 版本的 decoder 模拟了数据丢失 然后一顿操作靠其他帧修复 这里省略, 直接按 frame 解码
-	// 这个 go 库也是这么做的
-	// https://github.com/wdvxdr1123/go-silk/blob/main/silk.go
+	// C 版本的 decoder 模拟了数据丢失 然后一顿操作靠其他帧修复
+	// 这里不模拟丢包, 但是真实丢失的包(长度为 0)同样会在后续包中查找冗余数据修复
 	for {
-		blockIndex++
-		// 参考格式说明
-		// https://wufengxue.github.io/2019/04/17/wechat-voice-codec-amr.html
-		// 文件头之后，就是每个 block, 先是 16 字节的 block 大小 n，然后是 n 个字节内容
-		// 最后是 footer 部分，内容是 0xffff, 也可以看做是一个 block(大小是 -1，没有内容)
-
-		var nByte int16 // 先读取 block 大小, 占两个字节，用 int16 接收
-		err = binary.Read(reader, binary.LittleEndian, &nByte)
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				err = nil
-				log("packet=%d, EOF when read block size", blockIndex)
-				break
-			}
-			log("packet=%d, read block size err=%+v", blockIndex, err)
-			return fmt.Errorf("failed to read block size: %w", err)
+		payload, err := queue.Next()
+		if errors.Is(err, io.EOF) {
+			break
 		}
-		log("packet=%d, block size=%d", blockIndex, nByte)
-		if nByte < 0 {
-			break // 是 footer 部分, 没有 block 内容
+		if err != nil {
+			return err
 		}
-		if int(nByte) > len(in) { // 兜底 or 报错?
-			in = make([]byte, nByte)
+		packetIndex++
+		log("packet=%d, payload=%d bytes", packetIndex, len(payload))
+		if err = decodePacket(psDec, &decControl, payload, buf, out); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		// 再读取 block 内容，长度就是 nByte
-		n, err := io.ReadFull(reader, in[:nByte])
+// lbrrQueue reads packets ahead, so that the LBRR data of a lost packet can be found in the next packets.
+// 预读的数据包，丢包时在后面的包中查找冗余数据(LBRR)
+type lbrrQueue struct {
+	packets packetReader
+	queue   [][]byte
+	eof     bool
+}
+
+// Next returns the payload of the next packet, an empty payload means the packet is lost and no LBRR data found.
+func (q *lbrrQueue) Next() ([]byte, error) {
+	for !q.eof && len(q.queue) <= MAX_LBRR_DELAY {
+		packet, err := q.packets.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			q.eof = true
+			break
+		}
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				err = nil
-				log("packet=%d, EOF when read block data", blockIndex)
-				break
-			}
-			warn("packet=%d, read block data err=%+v", blockIndex, err)
-			return fmt.Errorf("failed to read block: %w", err)
+			return nil, err
 		}
-		if n != int(nByte) {
-			log("packet=%d, read block data invalid, read %d bytes, expected %d", blockIndex, n, nByte)
-			return fmt.Errorf("invalid block")
+		q.queue = append(q.queue, packet)
+	}
+	if len(q.queue) == 0 {
+		return nil, io.EOF
+	}
+	var payload = q.queue[0]
+	q.queue = q.queue[1:]
+	if len(payload) == 0 {
+		payload = searchLBRR(q.queue)
+		log("lost packet, found LBRR data=%d bytes", len(payload))
+	}
+	return payload, nil
+}
+
+// searchLBRR search for LBRR(Low Bit Rate Redundancy) data of a lost packet in the next packets.
+// 在后续的包中查找丢失包的冗余数据
+func searchLBRR(next [][]byte) []byte {
+	var lbrr = make([]byte, MAX_BYTES_PER_FRAME*MAX_INPUT_FRAMES)
+	for i, packet := range next {
+		if len(packet) == 0 {
+			continue
 		}
+		var nLBRRBytes C.SKP_int16
+		// void SKP_Silk_SDK_search_for_LBRR(
+		//     const SKP_uint8  *inData,       /* I:   Encoded input vector     */
+		//     const SKP_int    nBytesIn,      /* I:   Number of input Bytes    */
+		//     SKP_int          lost_offset,   /* I:   Offset from lost packet  */
+		//     SKP_uint8        *LBRRData,     /* O:   LBRR payload             */
+		//     SKP_int16        *nLBRRBytes    /* O:   Number of LBRR Bytes     */
+		// );
+		C.SKP_Silk_SDK_search_for_LBRR(
+			(*C.SKP_uint8)(unsafe.Pointer(&packet[0])),
+			C.SKP_int(len(packet)),
+			C.SKP_int(i+1),
+			(*C.SKP_uint8)(unsafe.Pointer(&lbrr[0])),
+			&nLBRRBytes,
+		)
+		if nLBRRBytes > 0 {
+			return lbrr[:nLBRRBytes]
+		}
+	}
+	return nil
+}
 
+// decodePacket decodes one packet, which may contains several 20ms frames.
+// An empty payload means the packet is lost, the decoder will generate concealment audio(PLC).
+// 解码一个数据包(可能包含多帧), payload 为空表示丢包，解码器会生成补偿音频
+func decodePacket(psDec unsafe.Pointer, decControl *C.SKP_SILK_SDK_DecControlStruct, payload, buf []byte, out io.Writer) error {
+	var (
+		lost    = len(payload) == 0
+		in      *C.SKP_uint8
+		frames  int
+		samples C.SKP_int16
+	)
+	if !lost {
+		in = (*C.SKP_uint8)(unsafe.Pointer(&payload[0]))
+	}
+	for {
 		// 解码
-		C.SKP_Silk_SDK_Decode(
+		ret := C.SKP_Silk_SDK_Decode(
 			psDec,                                   // State
-			&decControl,                             // Control Structure
-			0,                                       // 0: no loss, 1 loss
-			(*C.SKP_uint8)(unsafe.Pointer(&in[0])),  // Encoded input vector
-			C.SKP_int(n),                            // Number of input bytes
+			decControl,                              // Control Structure
+			C.SKP_int(bool2int(lost)),               // 0: no loss, 1 loss
+			in,                                      // Encoded input vector
+			C.SKP_int(len(payload)),                 // Number of input bytes
 			(*C.SKP_int16)(unsafe.Pointer(&buf[0])), // Decoded output speech vector
-			(*C.SKP_int16)(unsafe.Pointer(&nByte)),  // Number of samples (vector/decoded)
+			&samples,                                // Number of samples (vector/decoded)
 		)
+		if ret != 0 {
+			warn("decode failed, ret=%d", ret)
+		}
+		frames++
 		// buf 是 []byte 类型，但是实际上 SKP_Silk_SDK_Decode 输出的是 SKP_int16 数组
-		// 也就是说写入了 nByte 个 SKP_int16, 所以按 []byte 计算需要 *2
-		_, err = out.Write(buf[:nByte*2])
-		if err != nil {
+		// 也就是说写入了 samples 个 SKP_int16, 所以按 []byte 计算需要 *2
+		if _, err := out.Write(buf[:samples*2]); err != nil {
 			return fmt.Errorf("failed to write decode data: %w", err)
 		}
+		if lost {
+			// 丢包时按上一个包的帧数生成补偿音频
+			if frames >= int(decControl.framesPerPacket) {
+				break
+			}
+		} else if decControl.moreInternalDecoderFrames == 0 || frames >= MAX_INPUT_FRAMES {
+			// 一个包可能有多帧，需要循环解码
+			break
+		}
 	}
 	return nil
 }
 
+func bool2int(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 var Verbose = false
 
+// log prints to stderr, so it does not mix with the output written to stdout.
 func log(msg string, args ...any) {
 	if Verbose {
-		fmt.Printf("[INFO][silk] "+msg+"\n", args...)
+		fmt.Fprintf(os.Stderr, "[INFO][silk] "+msg+"\n", args...)
 	}
 }
 
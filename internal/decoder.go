@@ -0,0 +1,268 @@
+package internal
+
+/*
+#include "SKP_Silk_SDK_API.h"
+*/
+import "C"
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"unsafe"
+)
+
+// seekPreRoll is the number of packets decoded before the target packet after seeking,
+// to stabilize the LPC/LTP state of the decoder, their samples are discarded.
+// 跳转后在目标包之前预先解码的包数, 用于稳定解码器状态, 这些包的输出会被丢弃
+const seekPreRoll = 10
+
+// Decoder decodes a silk stream to pcm incrementally, it implements io.Reader.
+// When the source is an io.ReadSeeker, SeekSample/SeekTime can jump to any position.
+// 流式解码器, 源数据实现了 io.ReadSeeker 时可以跳转
+type Decoder struct {
+	src     io.Reader
+	cfg     *DecodeCfg
+	base    int64 // 源数据中 silk 流开始的位置
+	queue   *lbrrQueue
+	psDec   unsafe.Pointer
+	free    func()
+	control C.SKP_SILK_SDK_DecControlStruct
+	buf     []byte
+	pcm     bytes.Buffer // 已解码但还未被读取的数据
+	skip    int64        // 跳转后需要丢弃的字节数
+	pos     int64        // 下一次 Read 返回的第一个采样点的位置
+	index   *Index
+	ogg     *oggReader // Ogg 流的 ID header 和序列号, 跳转时使用
+	agc     *agc       // 响度标准化, 流式解码时无法预先测量整段响度
+	partial int64      // 多声道输出时, 已读取的不完整采样点的字节数
+}
+
+// NewDecoder creates a Decoder, the Decoder should be closed after use to release the decoder state.
+// 创建流式解码器, 使用完毕后需要 Close 释放解码器内存
+func NewDecoder(src io.Reader, opts ...DecodeOpt) (*Decoder, error) {
+	var cfg = buildDecodeCfg(opts...)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return newDecoder(src, cfg)
+}
+
+func newDecoder(src io.Reader, cfg *DecodeCfg) (*Decoder, error) {
+	log("decode option: %#v", cfg)
+	var d = &Decoder{
+		src: src,
+		cfg: cfg,
+		buf: make([]byte, FRAME_LENGTH_MS*MAX_API_FS_KHZ*2*2),
+	}
+	if cfg.Normalize != nil {
+		d.agc = newAGC(cfg.Normalize, cfg.SampleRate)
+	}
+	if seeker, ok := src.(io.Seeker); ok {
+		base, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the position of source: %w", err)
+		}
+		d.base = base
+	}
+	packets, err := openPackets(bufio.NewReader(src), cfg)
+	if err != nil {
+		return nil, err
+	}
+	if ogg, ok := packets.(*oggReader); ok {
+		d.ogg = ogg
+	}
+	d.psDec, d.free = malloc(getDecoderSize())
+	d.reset(packets)
+	return d, nil
+}
+
+// reset resets the decoder state and reads packets from the reader.
+func (d *Decoder) reset(packets packetReader) {
+	initDecoder(d.psDec)
+	d.queue = &lbrrQueue{packets: packets}
+	d.control = C.SKP_SILK_SDK_DecControlStruct{}
+	d.control.API_sampleRate = C.SKP_int32(d.cfg.SampleRate)
+	d.control.framesPerPacket = C.SKP_int(1)
+	d.pcm.Reset()
+	d.skip = 0
+	d.partial = 0
+}
+
+// Read reads decoded pcm(16bit, little endian unless PCMBigEndian is set, interleaved when Channels > 1) to p.
+func (d *Decoder) Read(p []byte) (int, error) {
+	if d.psDec == nil {
+		return 0, fmt.Errorf("decoder is closed")
+	}
+	for d.pcm.Len() == 0 {
+		if limit := d.cfg.outputSamples(); limit >= 0 && d.pos >= limit {
+			return 0, io.EOF // 末尾的填充
+		}
+		payload, err := d.queue.Next()
+		if err != nil {
+			return 0, err
+		}
+		if err = decodePacket(d.psDec, &d.control, payload, d.buf, &d.pcm); err != nil {
+			return 0, err
+		}
+		// 预读的数据包到达 footer 时已经读取了元数据, 最后一个数据包的填充可以去掉
+		d.cfg.applyMetadata(d.queue.packets)
+		if d.skip > 0 {
+			var n = d.skip
+			if n > int64(d.pcm.Len()) {
+				n = int64(d.pcm.Len())
+			}
+			d.pcm.Next(int(n))
+			d.skip -= n
+		}
+		if limit := d.cfg.outputSamples(); limit >= 0 && int64(d.pcm.Len()) > (limit-d.pos)*2 {
+			d.pcm.Truncate(int(limit-d.pos) * 2)
+		}
+		if d.agc != nil {
+			d.agc.process(d.pcm.Bytes())
+		}
+		if d.cfg.Gain != 0 {
+			applyGain(d.pcm.Bytes(), pcmToFloat(d.pcm.Bytes()), math.Pow(10, d.cfg.Gain/20))
+		}
+		if d.cfg.Channels > 1 {
+			var pcm = upmix(d.pcm.Bytes(), d.cfg.Channels)
+			d.pcm.Reset()
+			d.pcm.Write(pcm)
+		}
+		if d.cfg.PCMBigEndian {
+			swapBytes(d.pcm.Bytes())
+		}
+	}
+	n, _ := d.pcm.Read(p)
+	var frame = int64(2 * d.channels())
+	d.partial += int64(n)
+	d.pos += d.partial / frame
+	d.partial %= frame
+	return n, nil
+}
+
+func (d *Decoder) channels() int {
+	if d.cfg.Channels > 1 {
+		return d.cfg.Channels
+	}
+	return 1
+}
+
+// Position returns the position(in samples) of the next sample returned by Read.
+// 下一次 Read 返回的第一个采样点的位置
+func (d *Decoder) Position() int64 {
+	return d.pos
+}
+
+// Index returns the packet index of the stream, it is built on first use when not set by SetIndex.
+// The source must be an io.ReadSeeker, it is restored to the current position afterwards, so Read continues.
+// 返回数据包索引, 没有通过 SetIndex 设置时, 第一次调用会读取整个数据流建立索引, 之后恢复读取位置
+func (d *Decoder) Index() (*Index, error) {
+	if d.index != nil {
+		return d.index, nil
+	}
+	seeker, ok := d.src.(io.ReadSeeker)
+	if !ok {
+		return nil, fmt.Errorf("seeking requires an io.ReadSeeker source")
+	}
+	current, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the position of source: %w", err)
+	}
+	if _, err = seeker.Seek(d.base, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek source: %w", err)
+	}
+	index, err := buildIndex(seeker, d.cfg)
+	// 已缓冲的数据包不受影响, 源数据回到原来的位置即可继续读取
+	if _, seekErr := seeker.Seek(current, io.SeekStart); err == nil && seekErr != nil {
+		err = fmt.Errorf("failed to seek source: %w", seekErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.index = index
+	return index, nil
+}
+
+// SetIndex sets the index(e.g. cached by Index.MarshalBinary) of the stream, so it is not rebuilt on seek.
+// 设置数据包索引(例如之前缓存的索引), 跳转时不需要再读取整个数据流
+func (d *Decoder) SetIndex(index *Index) {
+	d.index = index
+}
+
+// SeekTime seeks to the time position, see SeekSample.
+// 跳转到指定时间
+func (d *Decoder) SeekTime(t time.Duration) error {
+	return d.SeekSample(int64(t) * int64(d.cfg.SampleRate) / int64(time.Second))
+}
+
+// SeekSample seeks to the sample position(counted at the decode sample rate).
+// It positions at the packets before the target, resets the decoder state, and discards the warm-up samples.
+// 跳转到指定采样点: 定位到目标之前的数据包, 重置解码器状态, 预先解码几个包并丢弃这些输出
+func (d *Decoder) SeekSample(sample int64) error {
+	if d.psDec == nil {
+		return fmt.Errorf("decoder is closed")
+	}
+	if sample < 0 {
+		return fmt.Errorf("invalid seek position: %d", sample)
+	}
+	index, err := d.Index()
+	if err != nil {
+		return err
+	}
+	var (
+		scale = func(n int64) int64 { return n * int64(d.cfg.SampleRate) / int64(index.SampleRate) }
+		start int
+	)
+	if len(index.Packets) > 0 {
+		start = index.entry(sample * int64(index.SampleRate) / int64(d.cfg.SampleRate)) // 目标所在的包
+		start -= seekPreRoll
+		if start < 0 {
+			start = 0
+		}
+	}
+	var packets packetReader = &bareReader{} // 空流
+	if start < len(index.Packets) {
+		var entry = index.Packets[start]
+		if _, err = d.src.(io.Seeker).Seek(d.base+entry.Offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek source: %w", err)
+		}
+		var reader = bufio.NewReader(d.src)
+		if d.cfg.PacketLengths != nil {
+			packets = &bareReader{reader: reader, lengths: d.cfg.PacketLengths[start:]}
+		} else if d.ogg != nil {
+			// 同一页中可能有多个包, 跳过目标之前的包
+			var ogg = d.ogg.seek(reader, entry.Offset)
+			for i := start - 1; i >= 0 && index.Packets[i].Offset == entry.Offset; i-- {
+				if _, err = ogg.ReadPacket(); err != nil {
+					return fmt.Errorf("failed to seek source: %w", err)
+				}
+			}
+			packets = ogg
+		} else {
+			prefix, _ := newLengthPrefix(d.cfg.LengthSize, d.cfg.LengthBigEndian)
+			packets = &silkReader{reader: reader, prefix: prefix}
+		}
+		d.reset(packets)
+		d.skip = (sample - scale(entry.Sample)) * 2
+	} else {
+		d.reset(packets)
+	}
+	log("seek to sample=%d, start packet=%d, skip=%d bytes", sample, start, d.skip)
+	d.pos = sample
+	return nil
+}
+
+// Close releases the decoder state.
+func (d *Decoder) Close() error {
+	if d.psDec == nil {
+		return errors.New("decoder is already closed")
+	}
+	d.free()
+	d.psDec = nil
+	return nil
+}
@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecoderSeek(t *testing.T) {
+	pcm, err := os.ReadFile("../cmd/testdata/hao.decode.pcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	silk, err := Encode(bytes.NewReader(pcm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Decode(bytes.NewReader(silk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewDecoder(bytes.NewReader(silk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	got, err := io.ReadAll(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Decoder output differs from Decode, len=%d, want=%d", len(got), len(want))
+	}
+
+	index, err := d.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Samples != int64(len(want)/2) || index.Packets[0].Offset != int64(HeaderLen) {
+		t.Errorf("unexpected index: samples=%d, first=%+v", index.Samples, index.Packets[0])
+	}
+	data, err := index.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cached Index
+	if err = cached.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&cached, index) {
+		t.Errorf("unmarshaled index differs")
+	}
+
+	// 从头开始与完整解码完全一致
+	d.SetIndex(&cached)
+	if err = d.SeekTime(0); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = io.ReadAll(d); !bytes.Equal(got, want) {
+		t.Errorf("output after seeking to 0 differs")
+	}
+
+	// 跳转到中间, 预解码后输出应与完整解码接近
+	var target = index.Samples / 2
+	if err = d.SeekTime(time.Duration(target) * time.Second / defaultSampleRate); err != nil {
+		t.Fatal(err)
+	}
+	if d.Position() != target {
+		t.Errorf("Position()=%d, want %d", d.Position(), target)
+	}
+	if got, _ = io.ReadAll(d); len(got) != len(want)-int(target)*2 {
+		t.Fatalf("read %d bytes after seek, want %d", len(got), len(want)-int(target)*2)
+	}
+	var signal, noise float64
+	for i := 0; i < len(got)/2; i++ {
+		var a = float64(int16(binary.LittleEndian.Uint16(want[int(target)*2+i*2:])))
+		var b = float64(int16(binary.LittleEndian.Uint16(got[i*2:])))
+		signal += a * a
+		noise += (a - b) * (a - b)
+	}
+	if noise > signal*0.001 {
+		t.Errorf("output after seek differs too much, signal=%g, noise=%g", signal, noise)
+	}
+}
+
+func TestDecoderIndexBeforeRead(t *testing.T) {
+	pcm, err := os.ReadFile("../cmd/testdata/hao.decode.pcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	silk, err := Encode(bytes.NewReader(pcm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Decode(bytes.NewReader(silk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDecoder(bytes.NewReader(silk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	// 读取前和读取中途建立索引, 都不影响后续的读取
+	if _, err = d.Index(); err != nil {
+		t.Fatal(err)
+	}
+	var head = make([]byte, 1000)
+	if _, err = io.ReadFull(d, head); err != nil {
+		t.Fatal(err)
+	}
+	d.SetIndex(nil)
+	if _, err = d.Index(); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := append(head, rest...); !bytes.Equal(got, want) {
+		t.Errorf("read %d bytes after Index(), want %d", len(got), len(want))
+	}
+}
+
+func TestDecoderSeekOgg(t *testing.T) {
+	pcm, err := os.ReadFile("../cmd/testdata/hao.decode.pcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ogg, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) { ec.Ogg = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Decode(bytes.NewReader(ogg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDecoder(bytes.NewReader(ogg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// 跳转到第二页中间的包, 需要跳过同一页中前面的包
+	var target = int64(oggPagePacket+oggPagePacket/2) * FRAME_LENGTH_MS * defaultSampleRate / 1000
+	if err = d.SeekSample(target); err != nil {
+		t.Fatal(err)
+	}
+	if d.Position() != target {
+		t.Errorf("Position()=%d, want %d", d.Position(), target)
+	}
+	got, err := io.ReadAll(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want)-int(target)*2 {
+		t.Fatalf("read %d bytes after seek, want %d", len(got), len(want)-int(target)*2)
+	}
+	var signal, noise float64
+	for i := 0; i < len(got)/2; i++ {
+		var a = float64(int16(binary.LittleEndian.Uint16(want[int(target)*2+i*2:])))
+		var b = float64(int16(binary.LittleEndian.Uint16(got[i*2:])))
+		signal += a * a
+		noise += (a - b) * (a - b)
+	}
+	if noise > signal*0.001 {
+		t.Errorf("output after seek differs too much, signal=%g, noise=%g", signal, noise)
+	}
+
+	if err = d.SeekSample(0); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = io.ReadAll(d); !bytes.Equal(got, want) {
+		t.Errorf("output after seeking to 0 differs")
+	}
+}
@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// 按数据包剪切、拼接 silk 文件, 不需要重新编码, 所以不会损失音质.
+// 输出格式与(第一个)输入一致: 有 STX 前缀时没有 footer, 否则以 footer 结尾.
+
+// silkSource is an opened silk v3 file.
+type silkSource struct {
+	packets packetReader
+	stx     bool
+	frames  int // 上一个包的帧数, 丢包时按上一个包计算时长
+}
+
+func openSilk(src io.Reader) (*silkSource, error) {
+	var reader = bufio.NewReader(src)
+	head, err := reader.Peek(sniffLen)
+	if len(head) == 0 {
+		return nil, fmt.Errorf("failed to peek file header: %w", err)
+	}
+	var format = DetectFormat(head)
+	if format != FormatSilk && format != FormatSilkStx {
+		return nil, newFormatError(format, head)
+	}
+	if err = checkHeader(reader); err != nil {
+		return nil, err
+	}
+	return &silkSource{packets: &silkReader{reader: reader}, stx: format == FormatSilkStx, frames: 1}, nil
+}
+
+// next reads the next packet and its duration.
+func (s *silkSource) next() ([]byte, time.Duration, error) {
+	packet, err := s.packets.ReadPacket()
+	if err != nil {
+		return nil, 0, err
+	}
+	if n := packetFrames(packet); n > 0 {
+		s.frames = n
+	}
+	return packet, time.Duration(s.frames*FRAME_LENGTH_MS) * time.Millisecond, nil
+}
+
+// Cut copies the packets between from and to of the silk file src to dst, to <= 0 means the end of src.
+// A packet is kept when it starts in [from, to), so the result is aligned to the packet boundaries.
+// 按数据包剪切 silk 文件, 保留开始时间在 [from, to) 之间的数据包, to <= 0 表示直到文件结尾
+func Cut(src io.Reader, dst io.Writer, from, to time.Duration) error {
+	if to > 0 && to <= from {
+		return fmt.Errorf("invalid range: from=%v, to=%v", from, to)
+	}
+	in, err := openSilk(src)
+	if err != nil {
+		return err
+	}
+	out, err := newSilkWriter(dst, &EncodeCfg{Stx: in.stx})
+	if err != nil {
+		return err
+	}
+	var pos time.Duration
+	for to <= 0 || pos < to {
+		packet, d, err := in.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if pos >= from {
+			if err = out.WritePacket(packet); err != nil {
+				return err
+			}
+		}
+		pos += d
+	}
+	log("cut from=%v, to=%v, end=%v", from, to, pos)
+	return out.Close()
+}
+
+// Concat joins the silk files srcs into dst, the output has STX flag if the first input has.
+// All inputs must have the same internal sampling rate.
+// 拼接多个 silk 文件, 输出格式与第一个文件一致(是否有 STX), 所有输入的内部采样率必须相同
+func Concat(dst io.Writer, srcs ...io.Reader) error {
+	if len(srcs) == 0 {
+		return fmt.Errorf("no input to concat")
+	}
+	var (
+		stx     bool
+		fsKHz   int
+		packets [][]byte
+	)
+	// 先读取所有输入并检查采样率, 出错时不会输出不完整的文件
+	for i, src := range srcs {
+		in, err := openSilk(src)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+		if i == 0 {
+			stx = in.stx
+		}
+		var rate int // 内部采样率会在编码时自适应调整, 以最大值为准
+		for {
+			packet, err := in.packets.ReadPacket()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("input %d: %w", i, err)
+			}
+			if toc, ok := readTOC(packet); ok && int(toc.fs_kHz) > rate {
+				rate = int(toc.fs_kHz)
+			}
+			packets = append(packets, packet)
+		}
+		log("concat input %d: stx=%v, internal sample rate=%dkHz", i, in.stx, rate)
+		if fsKHz == 0 {
+			fsKHz = rate
+		} else if rate != fsKHz && rate != 0 {
+			return fmt.Errorf("input %d: internal sample rate %dkHz differs from %dkHz", i, rate, fsKHz)
+		}
+	}
+	out, err := newSilkWriter(dst, &EncodeCfg{Stx: stx})
+	if err != nil {
+		return err
+	}
+	for _, packet := range packets {
+		if err = out.WritePacket(packet); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
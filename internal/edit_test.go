@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCutConcat(t *testing.T) {
+	pcm, err := os.ReadFile("../cmd/testdata/hao.decode.pcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(opt func(*EncodeCfg)) []byte {
+		data, err := Encode(bytes.NewReader(pcm), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	var (
+		silk   = encode(func(ec *EncodeCfg) {})
+		stx    = encode(func(ec *EncodeCfg) { ec.Stx = true })
+		narrow = encode(func(ec *EncodeCfg) { ec.MaxInternalSampleRate = 8000 })
+		frame  = FRAME_LENGTH_MS * defaultSampleRate / 1000 * 2 // 每个包解码后的字节数
+	)
+	all, err := Decode(bytes.NewReader(silk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cut bytes.Buffer
+	if err = Cut(bytes.NewReader(stx), &cut, 200*time.Millisecond, 610*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if cut.Bytes()[0] != STX {
+		t.Errorf("STX flag should be kept")
+	}
+	if got, err := Decode(&cut); err != nil || len(got) != 21*frame {
+		t.Errorf("decoded %d bytes, want %d, err=%v", len(got), 21*frame, err)
+	}
+
+	var joined bytes.Buffer
+	if err = Concat(&joined, bytes.NewReader(silk), bytes.NewReader(stx)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(joined.Bytes(), []byte(Header)) || !bytes.HasSuffix(joined.Bytes(), []byte{0xff, 0xff}) {
+		t.Errorf("output should follow the first input: header and footer")
+	}
+	if got, err := Decode(&joined); err != nil || len(got) != 2*len(all) {
+		t.Errorf("decoded %d bytes, want %d, err=%v", len(got), 2*len(all), err)
+	}
+
+	if err = Concat(&joined, bytes.NewReader(silk), bytes.NewReader(narrow)); err == nil {
+		t.Errorf("expected error of different internal sample rate")
+	}
+	if err = Cut(bytes.NewReader(pcm), &cut, 0, 0); err == nil {
+		t.Errorf("expected error of unsupported format")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 	"unsafe"
 )
 
@@ -21,6 +22,7 @@ const (
 	MAX_INPUT_FRAMES    = 5
 	FRAME_LENGTH_MS     = 20
 	MAX_API_FS_KHZ      = 48
+	MAX_LBRR_DELAY      = 2 // 丢包时最多向后查找几个包的冗余数据
 )
 
 type EncodeCfg struct {
@@ -33,14 +35,35 @@ type EncodeCfg struct {
 	ComplexityMode        int
 	BitRate               int
 	Stx                   bool
+	Ogg                   bool          // output as Ogg stream instead of silk v3 file
+	NoHeader              bool          // output length-prefixed blocks only, without file header and footer
+	LengthSize            int           // size of the block length prefix in bytes: 1, 2 or 4, 0 means 2
+	LengthBigEndian       bool          // write the block length prefix in big endian
+	PacketLengths         *[]int        // output bare packets without length prefix, and append their lengths here
+	TrimSilence           bool          // drop leading and trailing silence before encoding
+	TrimThresholdDb       float64       // frames whose RMS level(dBFS) is below it are silent
+	TrimPadding           time.Duration // silence kept before and after the sound
+	Normalize             *NormalizeCfg // normalize the loudness of input pcm before encoding, nil means disabled
+	Channels              int           // number of interleaved channels of input pcm, 0 or 1 means mono
+	Channel               int           // keep only this channel(starting from 1) when downmixing, 0 means average all channels
+	PCMBigEndian          bool          // the input pcm is big endian
+	Dither                bool          // add TPDF dither when converting float samples to 16bit(EncodeFloat32)
+	Preset                string        // name of the preset applied, its constraints are checked before encoding
+	TargetSize            int64         // adjust the bitrate per frame to keep the output within this size in bytes, 0 means disabled
+	CBRPacketSize         int           // pad every packet to this size in bytes and constrain the bitrate accordingly, 0 means disabled
+	Stats                 *EncodeStats  // statistics of the encoding are stored here after encoding
+	TwoPass               bool          // analyze the whole input first, and allocate more bitrate to voiced frames within the average BitRate
+	FadeOut               bool          // fade out the last partial frame before zero padding it
+	Metadata              bool          // write the original length and creation time after the footer, ignored when there is no footer(e.g. Stx)
+	Tags                  Tags          // key/value pairs written after the footer, the output must have a footer
 }
 
 type EncodeOpt func(*EncodeCfg)
 
 func Encode(src io.Reader, opts ...EncodeOpt) ([]byte, error) {
 	var cfg = buildCfg(opts...)
-	if cfg.SampleRate > MAX_API_FS_KHZ*1000 || cfg.SampleRate < 0 {
-		return nil, fmt.Errorf("error: API sampling rate = %d out of range, valid range 8000 - 48000", cfg.SampleRate)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 	/* Print options */
 	log("encode options: %#v", cfg)
@@ -48,11 +71,25 @@ func Encode(src io.Reader, opts ...EncodeOpt) ([]byte, error) {
 	var out = &bytes.Buffer{}
 
 	/* Add Silk header to stream */
-	if cfg.Stx {
-		out.Write([]byte{STX})
+	packets, err := newPacketWriter(out, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := encodePackets(src, packets, cfg); err != nil {
+		return nil, err
 	}
-	out.Write([]byte(Header))
+	if err := packets.Close(); err != nil {
+		return nil, err
+	}
+	if cfg.Stats != nil {
+		cfg.Stats.finish(int64(out.Len()))
+	}
+	return out.Bytes(), nil
+}
 
+// encodePackets creates an encoder, and writes the encoded packets of src to out.
+func encodePackets(src io.Reader, out packetWriter, cfg *EncodeCfg) error {
 	/* Create Encoder */
 	var encSizeBytes = getEncoderSize()
 	var psEnc, free = malloc(encSizeBytes)
@@ -61,10 +98,135 @@ func Encode(src io.Reader, opts ...EncodeOpt) ([]byte, error) {
 	/* Reset Encoder */
 	initEncode(psEnc)
 
-	if err := doEncode(src, out, cfg, psEnc); err != nil {
+	if cfg.PCMBigEndian {
+		src = &byteSwapper{reader: src}
+	}
+	if cfg.Channels > 1 {
+		// silk 只支持单声道
+		mixer, err := newDownmixer(src, cfg.Channels, cfg.Channel-1)
+		if err != nil {
+			return err
+		}
+		src = mixer
+	}
+	if cfg.Normalize != nil {
+		// 两遍处理, 需要先读取全部 pcm
+		pcm, err := io.ReadAll(src)
+		if err != nil {
+			return fmt.Errorf("failed to read pcm: %w", err)
+		}
+		normalizePCM(pcm, cfg.SampleRate, cfg.Normalize)
+		src = bytes.NewReader(pcm)
+	}
+	return doEncode(src, out, cfg, psEnc)
+}
+
+// packetWriter writes encoded silk packets to the output container.
+// 将编码后的数据包写入输出格式中
+type packetWriter interface {
+	WritePacket(payload []byte) error
+	Close() error
+}
+
+func newPacketWriter(out io.Writer, cfg *EncodeCfg) (packetWriter, error) {
+	if cfg.Ogg {
+		return newOggWriter(out, cfg)
+	}
+	if cfg.PacketLengths != nil {
+		return &bareWriter{out: out, lengths: cfg.PacketLengths}, nil
+	}
+	return newSilkWriter(out, cfg)
+}
+
+// silkWriter writes packets as silk v3 file: [STX]#!SILK_V3 + (size + packet)* + [footer]
+// 写入 silk v3 格式: 文件头 + 每个数据块(长度+内容) + footer(stx 模式无 footer)
+// NoHeader 模式只写入数据块, 没有文件头和 footer
+type silkWriter struct {
+	out    io.Writer
+	prefix lengthPrefix
+	footer bool
+	meta   *Metadata // footer 之后写入的元数据, nil 表示不写入
+	tags   Tags      // footer 之后写入的标签
+}
+
+func newSilkWriter(out io.Writer, cfg *EncodeCfg) (*silkWriter, error) {
+	prefix, err := newLengthPrefix(cfg.LengthSize, cfg.LengthBigEndian)
+	if err != nil {
 		return nil, err
 	}
-	return out.Bytes(), nil
+	var w = &silkWriter{
+		out:    out,
+		prefix: prefix,
+		footer: !cfg.Stx && !cfg.NoHeader && prefix.hasFooter(),
+	}
+	if cfg.Metadata && w.footer {
+		w.meta = &Metadata{SampleRate: cfg.SampleRate, Created: time.Now()}
+	}
+	if w.footer {
+		w.tags = cfg.Tags
+	}
+	if cfg.NoHeader {
+		return w, nil
+	}
+	var header = []byte(Header)
+	if cfg.Stx {
+		header = append([]byte{STX}, header...)
+	}
+	if _, err := out.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write file header: %w", err)
+	}
+	return w, nil
+}
+
+func (w *silkWriter) WritePacket(payload []byte) error {
+	// 写入编码后的长度、内容
+	size, err := w.prefix.put(len(payload))
+	if err != nil {
+		return err
+	}
+	_, err = w.out.Write(size)
+	if err != nil {
+		warn("failed to write block size, err=%+v", err)
+		return fmt.Errorf("failed to write block size: %w", err)
+	}
+	_, err = w.out.Write(payload)
+	if err != nil {
+		warn("failed to write block data, err=%+v", err)
+		return fmt.Errorf("failed to write block data: %w", err)
+	}
+	return nil
+}
+
+func (w *silkWriter) Close() error {
+	if !w.footer {
+		return nil
+	}
+	// footer block
+	footer, _ := w.prefix.put(-1)
+	if _, err := w.out.Write(footer); err != nil {
+		return fmt.Errorf("failed to write footer: %w", err)
+	}
+	if w.meta != nil {
+		if err := writeChunk(w.out, metadataChunkID, w.meta.marshal()); err != nil {
+			return err
+		}
+	}
+	if len(w.tags) > 0 {
+		return writeChunk(w.out, tagsChunkID, w.tags.marshal())
+	}
+	return nil
+}
+
+// recordLength records the number of samples of the input in the metadata.
+func (w *silkWriter) recordLength(samples int64) {
+	if w.meta != nil {
+		w.meta.Samples = samples
+	}
+}
+
+// lengthRecorder is implemented by the packet writers that record the number of samples of the input.
+type lengthRecorder interface {
+	recordLength(samples int64)
 }
 
 func buildCfg(opts ...EncodeOpt) *EncodeCfg {
@@ -129,7 +291,7 @@ func buildEncControl(cfg *EncodeCfg) *C.SKP_SILK_SDK_EncControlStruct {
 	return &encControl
 }
 
-func doEncode(reader io.Reader, out io.Writer, cfg *EncodeCfg, psEnc unsafe.Pointer) error {
+func doEncode(reader io.Reader, out packetWriter, cfg *EncodeCfg, psEnc unsafe.Pointer) error {
 	const frameSizeReadFromFile_ms = 20
 	var (
 		/* Set Encoder parameters */
@@ -139,11 +301,52 @@ func doEncode(reader io.Reader, out io.Writer, cfg *EncodeCfg, psEnc unsafe.Poin
 		// 每次读取 frameSize 个 SKP_int16 大小
 		// 这里我们的 in 是 []byte 类型，所以需要 *2
 		in         = make([]byte, frameSize*2)
-		nBytes     = int16(MAX_BYTES_PER_FRAME * MAX_INPUT_FRAMES)
-		payload    = make([]byte, nBytes)
+		payload    = make([]byte, MAX_BYTES_PER_FRAME*MAX_INPUT_FRAMES)
 		blockIndex int
 	)
 	log("encode frameSize=%d", frameSize)
+	if cfg.TrimSilence {
+		var padding = int(cfg.TrimPadding / (frameSizeReadFromFile_ms * time.Millisecond))
+		reader = newSilenceTrimmer(reader, frameSize*2, cfg.TrimThresholdDb, padding)
+	}
+	var rc = newRateControl(cfg, encControl)
+	if cfg.TargetSize > 0 || cfg.TwoPass {
+		// 需要知道总帧数才能分配预算
+		pcm, err := io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read pcm: %w", err)
+		}
+		var frames = frameCount(len(pcm), cfg)
+		if cfg.TargetSize > 0 {
+			if err = rc.setTarget(cfg.TargetSize, containerOverhead(cfg, frames), frames); err != nil {
+				return err
+			}
+		}
+		if cfg.TwoPass {
+			rc.setWeights(analyzeFrames(pcm, cfg), cfg.BitRate)
+		}
+		reader = bytes.NewReader(pcm)
+	}
+	defer func() {
+		if r, ok := out.(lengthRecorder); ok {
+			r.recordLength(rc.stats.Samples)
+		}
+		if cfg.Stats != nil {
+			*cfg.Stats = rc.stats
+		}
+	}()
+	// 编码一帧, 每 PacketSizeMs 写入一个数据包
+	var encode = func(frame []byte, samples int) error {
+		packet, err := encodeFrame(psEnc, encControl, frame, payload)
+		if err != nil {
+			warn("%v", err)
+			return nil // 和 C 版本一样跳过这一帧
+		}
+		if packet, complete := rc.frame(packet, samples); complete {
+			return out.WritePacket(packet)
+		}
+		return nil
+	}
 	for {
 		blockIndex++
 
@@ -151,57 +354,84 @@ func doEncode(reader io.Reader, out io.Writer, cfg *EncodeCfg, psEnc unsafe.Poin
 		n, err := io.ReadFull(reader, in)
 		log("block=%d, read n=%d, err=%+v", blockIndex, n, err)
 		if errors.Is(err, io.EOF) {
-			err = nil
 			log("block=%d, EOF when read data", blockIndex)
 			break
 		}
-		if n < frameSize {
-			break
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("failed to read pcm: %w", err)
+		}
+		if n < len(in) {
+			// 最后不足一帧: 补零(或淡出后补零)
+			padFrame(in, n, cfg.FadeOut)
 		}
 
 		// 编码
-		nBytes = MAX_BYTES_PER_FRAME * MAX_INPUT_FRAMES
-		/**************************/
-		/* Encode frame with Silk */
-		/**************************/
-		// SKP_int SKP_Silk_SDK_Encode(
-		//     void                                *encState,      /* I/O: State                                           */
-		//     const SKP_SILK_SDK_EncControlStruct *encControl,    /* I:   Control status                                  */
-		//     const SKP_int16                     *samplesIn,     /* I:   Speech sample input vector                      */
-		//     SKP_int                             nSamplesIn,     /* I:   Number of samples in input vector               */
-		//     SKP_uint8                           *outData,       /* O:   Encoded output vector                           */
-		//     SKP_int16                           *nBytesOut      /* I/O: Number of bytes in outData (input: Max bytes)   */
-		// );
-		ret := C.SKP_Silk_SDK_Encode(
-			psEnc,
-			encControl,
-			(*C.SKP_int16)(unsafe.Pointer(&in[0])),
-			C.SKP_int(n/2), // in 是 []byte 类型，看做 SKP_int16 数组的话，长度需要 / 2
-			(*C.SKP_uint8)(unsafe.Pointer(&payload[0])), // 接收 encode 后的数据
-			(*C.SKP_int16)(unsafe.Pointer(&nBytes)),     // 接收 encode 后的长度
-		)
-		log("encode ret code=%d, encode payload size=%d, data=%x", ret, nBytes, payload[:nBytes])
-		if ret != 0 {
-			warn("encode failed, ret=%d", ret)
-			continue // or break?
+		if err = encode(in, (n+1)/2); err != nil {
+			return err
 		}
-
-		// 写入编码后的长度、内容
-		err = binary.Write(out, binary.LittleEndian, nBytes)
-		if err != nil {
-			warn("failed to write block size, err=%+v", err)
-			return fmt.Errorf("failed to write block size: %w", err)
+		if n < len(in) {
+			break
 		}
-		_, err = out.Write(payload[:nBytes])
-		if err != nil {
-			warn("failed to write block data, err=%+v", err)
-			return fmt.Errorf("failed to write block data: %w", err)
+	}
+	// 多帧数据包还未完成时, 用静音帧填满最后一个数据包
+	for i := range in {
+		in[i] = 0
+	}
+	for rc.pending > 0 {
+		if err := encode(in, 0); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	if !cfg.Stx {
-		binary.Write(out, binary.LittleEndian, int16(-1)) // footer block
+// padFrame zero pads the frame after n bytes of input, the input is faded out first when fadeOut is true.
+// An odd trailing byte is kept as the low byte of the last sample.
+// 最后不足一帧的输入补零, fadeOut 时先将这部分输入淡出
+func padFrame(frame []byte, n int, fadeOut bool) {
+	for i := n; i < len(frame); i++ {
+		frame[i] = 0
+	}
+	if !fadeOut {
+		return
+	}
+	var samples = (n + 1) / 2
+	for i := 0; i < samples; i++ {
+		var (
+			gain   = float64(samples-i) / float64(samples)
+			sample = float64(int16(binary.LittleEndian.Uint16(frame[i*2:])))
+		)
+		binary.LittleEndian.PutUint16(frame[i*2:], uint16(int16(sample*gain)))
 	}
+}
 
-	return nil
+// encodeFrame encodes a frame of 16bit pcm, the payload is the buffer to receive the packet.
+// An empty packet is returned when the packet is not complete(PacketSizeMs > 20).
+// 编码一帧 pcm, payload 是接收编码结果的缓冲区
+func encodeFrame(psEnc unsafe.Pointer, encControl *C.SKP_SILK_SDK_EncControlStruct, in, payload []byte) ([]byte, error) {
+	var nBytes = int16(len(payload))
+	/**************************/
+	/* Encode frame with Silk */
+	/**************************/
+	// SKP_int SKP_Silk_SDK_Encode(
+	//     void                                *encState,      /* I/O: State                                           */
+	//     const SKP_SILK_SDK_EncControlStruct *encControl,    /* I:   Control status                                  */
+	//     const SKP_int16                     *samplesIn,     /* I:   Speech sample input vector                      */
+	//     SKP_int                             nSamplesIn,     /* I:   Number of samples in input vector               */
+	//     SKP_uint8                           *outData,       /* O:   Encoded output vector                           */
+	//     SKP_int16                           *nBytesOut      /* I/O: Number of bytes in outData (input: Max bytes)   */
+	// );
+	ret := C.SKP_Silk_SDK_Encode(
+		psEnc,
+		encControl,
+		(*C.SKP_int16)(unsafe.Pointer(&in[0])),
+		C.SKP_int(len(in)/2), // in 是 []byte 类型，看做 SKP_int16 数组的话，长度需要 / 2
+		(*C.SKP_uint8)(unsafe.Pointer(&payload[0])), // 接收 encode 后的数据
+		(*C.SKP_int16)(unsafe.Pointer(&nBytes)),     // 接收 encode 后的长度
+	)
+	log("encode ret code=%d, encode payload size=%d, data=%x", ret, nBytes, payload[:nBytes])
+	if ret != 0 {
+		return nil, fmt.Errorf("encode failed, ret=%d", ret)
+	}
+	return payload[:nBytes], nil
 }
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestEncodePadding(t *testing.T) {
+	var pcm = sinePCM(defaultSampleRate, 1, 0.3)
+	for _, size := range []int{1, 959, 960, 962, 24000*2 + 245} {
+		for _, packetMs := range []int{20, 60} {
+			var (
+				input   = pcm[:size]
+				samples = int64(size+1) / 2
+				stats   EncodeStats
+				lengths []int
+				opts    = []EncodeOpt{func(ec *EncodeCfg) { ec.PacketSizeMs = packetMs; ec.Stats = &stats }}
+			)
+			silk, err := Encode(bytes.NewReader(input), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Samples != samples {
+				t.Errorf("size=%d packet=%dms: stats.Samples=%d, want %d", size, packetMs, stats.Samples, samples)
+			}
+			// 每个数据包都不为空, 最后一个数据包被填满
+			if _, err = Encode(bytes.NewReader(input), append(opts, func(ec *EncodeCfg) { ec.PacketLengths = &lengths })...); err != nil {
+				t.Fatal(err)
+			}
+			var frames = (size + 959) / 960
+			if want := (frames + packetMs/20 - 1) / (packetMs / 20); len(lengths) != want {
+				t.Errorf("size=%d packet=%dms: %d packets, want %d", size, packetMs, len(lengths), want)
+			}
+			for i, n := range lengths {
+				if n == 0 {
+					t.Errorf("size=%d packet=%dms: packet %d is empty", size, packetMs, i)
+				}
+			}
+
+			got, err := Decode(bytes.NewReader(silk))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int64(len(got)) < samples*2 || len(got)-size >= packetMs*defaultSampleRate/1000*2 {
+				t.Errorf("size=%d packet=%dms: decoded %d bytes", size, packetMs, len(got))
+			}
+			// 根据原始长度去掉填充
+			got, err = Decode(bytes.NewReader(silk), func(dc *DecodeCfg) { dc.OriginalSamples = stats.Samples })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int64(len(got)) != samples*2 {
+				t.Errorf("size=%d packet=%dms: decoded %d bytes with original length, want %d", size, packetMs, len(got), samples*2)
+			}
+			d, err := NewDecoder(bytes.NewReader(silk), func(dc *DecodeCfg) {
+				dc.OriginalSamples = stats.Samples * 2
+				dc.OriginalSampleRate = defaultSampleRate * 2
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err = io.ReadAll(d); err != nil || int64(len(got)) != samples*2 {
+				t.Errorf("size=%d packet=%dms: Decoder read %d bytes, err=%v", size, packetMs, len(got), err)
+			}
+			d.Close()
+		}
+	}
+}
+
+func TestEncoderPadding(t *testing.T) {
+	var pcm = sinePCM(defaultSampleRate, 1, 0.3)[:24000+701]
+	for _, packetMs := range []int{20, 40, 100} {
+		var opt = func(ec *EncodeCfg) { ec.PacketSizeMs = packetMs; ec.FadeOut = true }
+		want, err := Encode(bytes.NewReader(pcm), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		e, err := NewEncoder(&buf, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = e.Write(pcm); err != nil {
+			t.Fatal(err)
+		}
+		if err = e.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("packet=%dms: streaming encode differs from Encode: %d bytes, want %d", packetMs, buf.Len(), len(want))
+		}
+	}
+}
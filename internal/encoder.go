@@ -0,0 +1,251 @@
+package internal
+
+/*
+#include "SKP_Silk_SDK_API.h"
+#include "SKP_Silk_define.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unsafe"
+)
+
+// EncodeFeedback is passed to the EncodeController after each frame is encoded.
+// 每编码一帧后传给 EncodeController 的信息
+type EncodeFeedback struct {
+	Frame      int           // index of the frame, starting from 0
+	Position   time.Duration // duration encoded, including this frame
+	PacketSize int           // bytes of the packet output by this frame, 0 when the packet is not complete
+	Bytes      int64         // total bytes of the packets output
+	BitRate    int           // current target bitrate
+	PacketLoss int           // current packet loss estimate, in percent
+}
+
+// EncodeController is called after each frame is encoded, it can adjust the parameters of the encoder
+// by SetBitRate/SetPacketLoss/SetComplexity/SetFEC, e.g. according to the feedback of a congestion controller.
+// 每编码一帧后调用, 可以根据网络反馈等调用 SetBitRate 等方法调整编码参数, 从下一帧开始生效
+type EncodeController func(e *Encoder, feedback EncodeFeedback)
+
+// Encoder encodes 16bit pcm to silk incrementally, it implements io.WriteCloser.
+// The parameters can be changed during encoding, and take effect at the next frame.
+// 流式编码器, 编码过程中可以调整参数, 从下一帧开始生效
+type Encoder struct {
+	cfg        *EncodeCfg
+	out        packetWriter
+	written    *countingWriter
+	psEnc      unsafe.Pointer
+	free       func()
+	control    *C.SKP_SILK_SDK_EncControlStruct
+	in         bytes.Buffer // 还不足一帧的输入
+	frameSize  int          // 每帧输入的字节数(所有声道)
+	frame      []byte       // 单声道的一帧
+	payload    []byte
+	mixer      *downmixer
+	agc        *agc
+	rc         *rateControl
+	controller EncodeController
+	feedback   EncodeFeedback
+}
+
+// NewEncoder creates an Encoder writing to dst, the file header is written immediately.
+// The Encoder must be closed to write the footer and release the encoder state.
+// TrimSilence, TargetSize and TwoPass are not supported since they need the whole input, and Normalize uses automatic gain control.
+// In CBR mode the bitrate is adjusted per frame, overriding SetBitRate.
+// 创建流式编码器, 结束时需要 Close 以写入 footer 并释放编码器内存.
+// 不支持 TrimSilence, TargetSize 和 TwoPass(需要完整输入), 响度标准化使用自动增益控制; CBR 模式会逐帧调整码率, 覆盖 SetBitRate 的设置
+func NewEncoder(dst io.Writer, opts ...EncodeOpt) (*Encoder, error) {
+	var cfg = buildCfg(opts...)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.TrimSilence {
+		return nil, errors.New("TrimSilence is not supported by Encoder")
+	}
+	if cfg.TargetSize > 0 || cfg.TwoPass {
+		return nil, errors.New("TargetSize and TwoPass are not supported by Encoder")
+	}
+	log("encoder options: %#v", cfg)
+	var (
+		channels = 1
+		frame    = FRAME_LENGTH_MS * cfg.SampleRate / 1000 * 2
+		e        = &Encoder{cfg: cfg, frame: make([]byte, frame), payload: make([]byte, MAX_BYTES_PER_FRAME*MAX_INPUT_FRAMES)}
+	)
+	if cfg.Channels > 1 {
+		channels = cfg.Channels
+		mixer, err := newDownmixer(nil, cfg.Channels, cfg.Channel-1)
+		if err != nil {
+			return nil, err
+		}
+		e.mixer = mixer
+	}
+	e.frameSize = frame * channels
+	if cfg.Normalize != nil {
+		e.agc = newAGC(cfg.Normalize, cfg.SampleRate)
+	}
+	e.written = &countingWriter{writer: dst}
+	out, err := newPacketWriter(e.written, cfg)
+	if err != nil {
+		return nil, err
+	}
+	e.out = out
+	e.control = buildEncControl(cfg)
+	e.rc = newRateControl(cfg, e.control)
+	e.psEnc, e.free = malloc(getEncoderSize())
+	initEncode(e.psEnc)
+	e.feedback.BitRate, e.feedback.PacketLoss = int(e.control.bitRate), cfg.PacketLossPct
+	return e, nil
+}
+
+// Write encodes pcm(16bit, interleaved when Channels > 1), data less than a frame(20ms) is buffered.
+// 编码 pcm 数据, 不足一帧(20ms)的数据会先缓存
+func (e *Encoder) Write(pcm []byte) (int, error) {
+	if e.psEnc == nil {
+		return 0, errors.New("encoder is closed")
+	}
+	e.in.Write(pcm)
+	for e.in.Len() >= e.frameSize {
+		if err := e.encode(e.in.Next(e.frameSize), e.frameSize); err != nil {
+			return 0, err
+		}
+	}
+	return len(pcm), nil
+}
+
+// encode encodes a frame of input, n is the bytes of input in it, the rest is zero padding.
+func (e *Encoder) encode(in []byte, n int) error {
+	var frame = e.frame
+	if e.cfg.PCMBigEndian {
+		swapBytes(in)
+	}
+	if e.mixer != nil {
+		e.mixer.mix(in, frame)
+	} else {
+		copy(frame, in)
+	}
+	var (
+		sampleSize = e.frameSize / len(frame) * 2 // 每个采样点(所有声道)的字节数
+		samples    = (n + sampleSize - 1) / sampleSize
+	)
+	if n < len(in) {
+		padFrame(frame, samples*2, e.cfg.FadeOut)
+	}
+	if e.agc != nil {
+		e.agc.process(frame)
+	}
+	packet, err := encodeFrame(e.psEnc, e.control, frame, e.payload)
+	if err != nil {
+		warn("%v", err)
+		return nil // 和 C 版本一样跳过这一帧
+	}
+	packet, complete := e.rc.frame(packet, samples)
+	if complete {
+		if err = e.out.WritePacket(packet); err != nil {
+			return err
+		}
+	}
+
+	var fb = &e.feedback
+	fb.BitRate = int(e.control.bitRate)
+	fb.Position += FRAME_LENGTH_MS * time.Millisecond
+	fb.PacketSize = len(packet)
+	fb.Bytes += int64(len(packet))
+	if e.controller != nil {
+		e.controller(e, *fb)
+	}
+	fb.Frame++
+	return nil
+}
+
+// SetController sets the callback called after each frame is encoded, nil to remove it.
+// 设置每编码一帧后调用的回调函数
+func (e *Encoder) SetController(controller EncodeController) {
+	e.controller = controller
+}
+
+// SetBitRate sets the target bitrate, it takes effect at the next frame.
+// 设置目标码率, 从下一帧开始生效
+func (e *Encoder) SetBitRate(bps int) error {
+	if bps < C.MIN_TARGET_RATE_BPS || bps > C.MAX_TARGET_RATE_BPS {
+		return fmt.Errorf("invalid BitRate %d, valid range %d - %d", bps, C.MIN_TARGET_RATE_BPS, C.MAX_TARGET_RATE_BPS)
+	}
+	e.control.bitRate = C.SKP_int32(bps)
+	e.feedback.BitRate = bps
+	return nil
+}
+
+// SetPacketLoss sets the uplink loss estimate in percent(0-100), it takes effect at the next frame.
+// 设置丢包率估计(0-100), 从下一帧开始生效
+func (e *Encoder) SetPacketLoss(pct int) error {
+	if pct < 0 || pct > 100 {
+		return fmt.Errorf("invalid PacketLossPct %d, valid range 0 - 100", pct)
+	}
+	e.control.packetLossPercentage = C.SKP_int(pct)
+	e.feedback.PacketLoss = pct
+	return nil
+}
+
+// SetComplexity sets the complexity(0: low, 1: medium, 2: high), it takes effect at the next frame.
+// 设置复杂度(0=低, 1=中, 2=高), 从下一帧开始生效
+func (e *Encoder) SetComplexity(mode int) error {
+	if mode < 0 || mode > 2 {
+		return fmt.Errorf("invalid ComplexityMode %d, valid range 0 - 2", mode)
+	}
+	e.control.complexity = C.SKP_int(mode)
+	return nil
+}
+
+// SetFEC enables or disables inband FEC, it takes effect at the next frame.
+// FEC data is only produced when the packet loss estimate is high enough.
+// 开启或关闭带内 FEC, 从下一帧开始生效; 丢包率足够高时才会生成冗余数据
+func (e *Encoder) SetFEC(enable bool) {
+	if enable {
+		e.control.useInBandFEC = C.SKP_int(1)
+	} else {
+		e.control.useInBandFEC = C.SKP_int(0)
+	}
+}
+
+// Close encodes the buffered data less than a frame(zero padded), fills the last packet with silent frames,
+// writes the footer and releases the encoder state.
+// 编码缓存中不足一帧的数据(补零), 用静音帧填满最后一个数据包, 写入 footer 并释放编码器内存
+func (e *Encoder) Close() error {
+	if e.psEnc == nil {
+		return errors.New("encoder is already closed")
+	}
+	defer func() {
+		e.free()
+		e.psEnc = nil
+	}()
+	var in = make([]byte, e.frameSize)
+	if n := e.in.Len(); n > 0 {
+		copy(in, e.in.Next(n))
+		if err := e.encode(in, n); err != nil {
+			return err
+		}
+	}
+	for e.rc.pending > 0 {
+		for i := range in {
+			in[i] = 0
+		}
+		if err := e.encode(in, 0); err != nil {
+			return err
+		}
+	}
+	log("encoder closed, frames=%d, bytes=%d", e.feedback.Frame, e.feedback.Bytes)
+	if r, ok := e.out.(lengthRecorder); ok {
+		r.recordLength(e.rc.stats.Samples)
+	}
+	if err := e.out.Close(); err != nil {
+		return err
+	}
+	if e.cfg.Stats != nil {
+		*e.cfg.Stats = e.rc.stats
+		e.cfg.Stats.finish(e.written.n)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	var pcm = sinePCM(defaultSampleRate, 2, 0.3)
+	want, err := Encode(bytes.NewReader(pcm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	e, err := NewEncoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每次写入的数据不是整帧
+	for i := 0; i < len(pcm); i += 1000 {
+		var end = i + 1000
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+		if _, err = e.Write(pcm[i:end]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("streaming encode differs from Encode: %d bytes, want %d", buf.Len(), len(want))
+	}
+	if err = e.Close(); err == nil {
+		t.Errorf("expected error when closing twice")
+	}
+}
+
+func TestEncoderController(t *testing.T) {
+	var (
+		pcm    = noisePCM(defaultSampleRate * 4) // 噪声需要较高的码率, 正弦波达不到目标码率
+		buf    bytes.Buffer
+		before int // 调整码率之前的数据量
+		after  int
+	)
+	e, err := NewEncoder(&buf, func(ec *EncodeCfg) { ec.BitRate = 40000 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetController(func(e *Encoder, fb EncodeFeedback) {
+		switch {
+		case fb.Frame < 100:
+			before += fb.PacketSize
+		case fb.Frame == 100:
+			// 模拟拥塞控制: 降低码率, 提高丢包率估计
+			if err := e.SetBitRate(8000); err != nil {
+				t.Error(err)
+			}
+			if err := e.SetPacketLoss(20); err != nil {
+				t.Error(err)
+			}
+			e.SetFEC(true)
+			if err := e.SetComplexity(0); err != nil {
+				t.Error(err)
+			}
+		case fb.Frame > 110: // 跳过码率调整的过渡期
+			after += fb.PacketSize
+			if fb.BitRate != 8000 || fb.PacketLoss != 20 {
+				t.Errorf("unexpected feedback: %+v", fb)
+			}
+		}
+	})
+	if _, err = e.Write(pcm); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 前 100 帧 40kbps, 后 89 帧约 8kbps
+	if before/100 <= after/89*2 {
+		t.Errorf("bitrate not changed: %d bytes/frame before, %d bytes/frame after", before/100, after/89)
+	}
+	decoded, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(pcm) {
+		t.Errorf("decoded %d bytes, want %d", len(decoded), len(pcm))
+	}
+
+	if err = e.SetBitRate(1); err == nil {
+		t.Errorf("expected error for invalid bitrate")
+	}
+	if err = e.SetPacketLoss(101); err == nil {
+		t.Errorf("expected error for invalid packet loss")
+	}
+	if err = e.SetComplexity(3); err == nil {
+		t.Errorf("expected error for invalid complexity")
+	}
+}
+
+// noisePCM returns 16bit pcm of white noise.
+func noisePCM(samples int) []byte {
+	var (
+		noise ditherNoise
+		pcm   []byte
+	)
+	for i := 0; i < samples; i++ {
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(toInt16(0.2*noise.next())))
+	}
+	return pcm
+}
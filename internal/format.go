@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Format is the format of an input stream detected by DetectFormat.
+// 输入数据的格式
+type Format int
+
+const (
+	FormatUnknown Format = iota // 无法识别
+	FormatSilk                  // #!SILK_V3
+	FormatSilkStx               // 0x02 + #!SILK_V3, QQ/WeChat 使用的格式
+	FormatSilkRaw               // 没有文件头的 silk 数据块
+	FormatOggSilk               // Ogg 封装的 silk
+	FormatOgg                   // 其他 Ogg 格式(opus, vorbis 等)
+	FormatWAVSilk               // WAV 文件的 data 块中是 silk
+	FormatWAV                   // WAV 文件
+	FormatAMR                   // AMR-NB #!AMR\n
+	FormatAMRWB                 // AMR-WB #!AMR-WB\n
+	FormatMP3                   // MP3 文件
+	FormatPCM                   // 看起来像是 16bit 的 pcm 数据
+)
+
+const (
+	sniffLen    = 4096 // 识别格式时最多查看的字节数(bufio 默认缓冲区大小)
+	amrHeader   = "#!AMR\n"
+	amrWBHeader = "#!AMR-WB\n"
+)
+
+var formatNames = map[Format]string{
+	FormatUnknown: "unknown",
+	FormatSilk:    "silk v3",
+	FormatSilkStx: "silk v3 (with STX)",
+	FormatSilkRaw: "silk without header",
+	FormatOggSilk: "Ogg/silk",
+	FormatOgg:     "Ogg (not silk)",
+	FormatWAVSilk: "WAV/silk",
+	FormatWAV:     "WAV",
+	FormatAMR:     "AMR-NB",
+	FormatAMRWB:   "AMR-WB",
+	FormatMP3:     "MP3",
+	FormatPCM:     "PCM",
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// IsSilk reports whether the format can be decoded by Decode.
+// 是否是可以直接解码的 silk 格式
+func (f Format) IsSilk() bool {
+	switch f {
+	case FormatSilk, FormatSilkStx, FormatOggSilk, FormatWAVSilk:
+		return true
+	}
+	return false
+}
+
+// ErrUnsupportedFormat is returned(wrapped in *FormatError) when the input is not a decodable silk stream.
+// 输入不是可以解码的 silk 格式
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// FormatError is the error with the detected format of the input.
+type FormatError struct {
+	Format Format
+	Head   []byte // 输入的开头几个字节
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("%s: %s, file header=%q", ErrUnsupportedFormat, e.Format, e.Head)
+}
+
+func (e *FormatError) Is(target error) bool {
+	return target == ErrUnsupportedFormat
+}
+
+func newFormatError(f Format, head []byte) *FormatError {
+	if len(head) > 16 {
+		head = head[:16]
+	}
+	return &FormatError{Format: f, Head: append([]byte(nil), head...)}
+}
+
+// DetectFormat detects the format of the stream by its first bytes(at least 4096 bytes is recommended).
+// 根据开头的数据识别格式(建议至少传入 4096 字节)
+func DetectFormat(head []byte) Format {
+	switch {
+	case bytes.HasPrefix(head, []byte(Header)):
+		return FormatSilk
+	case len(head) > 0 && head[0] == STX && bytes.HasPrefix(head[1:], []byte(Header)):
+		return FormatSilkStx
+	case bytes.HasPrefix(head, []byte(OggMagic)):
+		if page, err := readOggPage(bytes.NewReader(head)); err == nil && bytes.HasPrefix(page.Data, []byte(OggHeadMagic)) {
+			return FormatOggSilk
+		}
+		return FormatOgg
+	case bytes.HasPrefix(head, []byte(amrHeader)):
+		return FormatAMR
+	case bytes.HasPrefix(head, []byte(amrWBHeader)):
+		return FormatAMRWB
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		if offset, _, ok := wavDataChunk(head); ok {
+			if f := DetectFormat(head[offset:]); f == FormatSilk || f == FormatSilkStx {
+				return FormatWAVSilk
+			}
+		}
+		return FormatWAV
+	case bytes.HasPrefix(head, []byte("ID3")),
+		len(head) >= 4 && head[0] == 0xff && head[1]&0xe0 == 0xe0 && head[1]&0x06 != 0 && head[2]&0xf0 != 0xf0:
+		// ID3 标签 或 MPEG audio 帧同步字(11 个 1) + layer 不为 0 + bitrate 不为 bad
+		return FormatMP3
+	case isRawSilk(head):
+		return FormatSilkRaw
+	case isPCM(head):
+		return FormatPCM
+	}
+	return FormatUnknown
+}
+
+// wavDataChunk finds the data chunk in a WAV file, returns the offset and size of chunk data.
+func wavDataChunk(head []byte) (offset, size int, ok bool) {
+	offset = 12
+	for offset+8 <= len(head) {
+		var id, n = string(head[offset : offset+4]), int(binary.LittleEndian.Uint32(head[offset+4:]))
+		offset += 8
+		if id == "data" {
+			return offset, n, true
+		}
+		offset += n + n%2 // chunk 按 2 字节对齐
+	}
+	return 0, 0, false
+}
+
+// isRawSilk reports whether head looks like length-prefixed silk packets without file header:
+// every complete packet in head must be valid, and the length chain must not be broken.
+// 没有文件头的 silk: 数据块长度合法, 且每个完整的数据包都能正常解析
+func isRawSilk(head []byte) bool {
+	var offset, packets int
+	for offset+2 <= len(head) {
+		var n = int(int16(binary.LittleEndian.Uint16(head[offset:])))
+		if n < 0 { // footer
+			return packets > 0
+		}
+		if n == 0 || n > MAX_BYTES_PER_FRAME*MAX_INPUT_FRAMES {
+			return false
+		}
+		if offset+2+n <= len(head) && !validPacket(head[offset+2:offset+2+n]) {
+			return false
+		}
+		offset += 2 + n
+		packets++
+	}
+	// 数据不足时, 至少要有 3 个完整的数据块才认为是 silk
+	return packets >= 3 || (packets > 0 && offset == len(head))
+}
+
+// validPacket checks the packet with SKP_Silk_SDK_get_TOC.
+func validPacket(packet []byte) bool {
+	return packetFrames(packet) > 0
+}
+
+// isPCM reports whether head looks like 16bit little-endian pcm:
+// neighbouring samples of audio are correlated, so their differences are smaller than the samples themselves.
+// All-zero or too short input is not classified as pcm.
+// 音频信号相邻采样点是相关的, 差值的幅度会明显小于采样值本身的幅度; 随机/压缩数据则没有这个特点
+func isPCM(head []byte) bool {
+	var samples = len(head) / 2
+	if samples < 64 {
+		return false
+	}
+	var sum, diff float64
+	var prev int16
+	for i := 0; i < samples; i++ {
+		var s = int16(binary.LittleEndian.Uint16(head[i*2:]))
+		sum += abs(float64(s))
+		if i > 0 {
+			diff += abs(float64(s) - float64(prev))
+		}
+		prev = s
+	}
+	return diff < sum*0.8 // 全零时 sum 为 0, 无法判断, 不认为是 pcm
+}
+
+func abs(f float64) float64 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	read := func(name string) []byte {
+		data, err := os.ReadFile("../cmd/testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	var (
+		stx = read("hao.amr")
+		pcm = read("hao.decode.pcm")
+		wav = func(data []byte) []byte {
+			var buf bytes.Buffer
+			buf.WriteString("RIFF")
+			binary.Write(&buf, binary.LittleEndian, uint32(4+8+16+8+len(data)))
+			buf.WriteString("WAVEfmt ")
+			binary.Write(&buf, binary.LittleEndian, []uint32{16, 1 | 1<<16, 24000, 48000, 2 | 16<<16})
+			buf.WriteString("data")
+			binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+			buf.Write(data)
+			return buf.Bytes()
+		}
+		random = make([]byte, 4096)
+	)
+	rand.New(rand.NewSource(1)).Read(random)
+	ogg, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) { ec.Ogg = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want Format
+	}{
+		{"silk", stx[1:], FormatSilk},
+		{"silk-stx", stx, FormatSilkStx},
+		{"silk-raw", stx[1+HeaderLen:], FormatSilkRaw},
+		{"ogg-silk", ogg, FormatOggSilk},
+		{"wav-silk", wav(stx), FormatWAVSilk},
+		{"wav", wav(pcm), FormatWAV},
+		{"amr", []byte("#!AMR\n\x3c\x48\xf5\x5f"), FormatAMR},
+		{"amr-wb", []byte("#!AMR-WB\n\x04\x10\x20"), FormatAMRWB},
+		{"mp3", read("hao.mp3"), FormatMP3},
+		{"pcm", pcm, FormatPCM},
+		{"random", random, FormatUnknown},
+		{"zero", make([]byte, 4096), FormatUnknown},
+		{"short-pcm", pcm[:100], FormatUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var head = tt.data
+			if len(head) > sniffLen {
+				head = head[:sniffLen]
+			}
+			if got := DetectFormat(head); got != tt.want {
+				t.Errorf("DetectFormat() = %v, want %v", got, tt.want)
+			}
+			_, err := Decode(bytes.NewReader(tt.data))
+			if tt.want.IsSilk() {
+				if err != nil {
+					t.Errorf("Decode() error = %+v", err)
+				}
+				return
+			}
+			var formatErr *FormatError
+			if !errors.Is(err, ErrUnsupportedFormat) || !errors.As(err, &formatErr) || formatErr.Format != tt.want {
+				t.Errorf("Decode() error = %+v, want format %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+// ID3v2.3 标签: 写在 mp3 文件开头, 只包含标题、艺术家、注释三个文本帧.
+// 纯 ASCII 文本使用 ISO-8859-1 编码, 否则使用带 BOM 的 UTF-16(v2.3 不支持 UTF-8).
+
+// ID3Tags is the ID3v2 tags of mp3, empty fields are not written.
+// mp3 的 ID3v2 标签, 为空的字段不写入
+type ID3Tags struct {
+	Title   string
+	Artist  string
+	Comment string
+}
+
+// marshal returns the ID3v2.3 tag.
+func (t *ID3Tags) marshal() []byte {
+	var frames []byte
+	frames = appendID3Frame(frames, "TIT2", id3Text(t.Title))
+	frames = appendID3Frame(frames, "TPE1", id3Text(t.Artist))
+	if t.Comment != "" {
+		// 编码 + 语言 + 描述(空) + 文本
+		var (
+			text = id3Text(t.Comment)
+			data = append([]byte{text[0]}, "eng"...)
+		)
+		if text[0] == 0 {
+			data = append(data, 0)
+		} else {
+			data = append(data, 0xFF, 0xFE, 0, 0)
+		}
+		frames = appendID3Frame(frames, "COMM", append(data, text[1:]...))
+	}
+	var header = []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 0}
+	var size = len(frames) // 4 字节 syncsafe 整数, 每字节只用低 7 位
+	for i := 9; i >= 6; i-- {
+		header[i] = byte(size & 0x7F)
+		size >>= 7
+	}
+	return append(header, frames...)
+}
+
+// appendID3Frame appends the frame when data has text.
+func appendID3Frame(frames []byte, id string, data []byte) []byte {
+	if len(data) <= 1 {
+		return frames
+	}
+	frames = append(frames, id...)
+	frames = binary.BigEndian.AppendUint32(frames, uint32(len(data)))
+	frames = append(frames, 0, 0) // flags
+	return append(frames, data...)
+}
+
+// id3Text returns the encoding byte and the encoded text.
+func id3Text(s string) []byte {
+	var ascii = true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return append([]byte{0}, s...)
+	}
+	var data = []byte{1, 0xFF, 0xFE} // UTF-16 小端序 BOM
+	for _, u := range utf16.Encode([]rune(s)) {
+		data = binary.LittleEndian.AppendUint16(data, u)
+	}
+	return data
+}
@@ -0,0 +1,222 @@
+package internal
+
+/*
+#include "SKP_Silk_SDK_API.h"
+*/
+import "C"
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+const (
+	indexMagic   = "SKIX"
+	indexVersion = 1
+)
+
+// IndexEntry is the position of a packet.
+// 数据包的位置
+type IndexEntry struct {
+	Offset int64 // byte offset of the packet(including its length prefix) from the start of the stream, for Ogg it's the offset of the page where the packet starts
+	Sample int64 // first sample of the packet, counted at Index.SampleRate
+}
+
+// Index records byte offset and sample position of every packet in a silk stream, used by Decoder to seek.
+// 记录每个数据包的字节偏移和采样位置, 用于解码时跳转
+type Index struct {
+	SampleRate int   // sample positions are counted at this rate
+	Samples    int64 // total samples of the stream
+	Packets    []IndexEntry
+}
+
+// BuildIndex reads the stream once and builds the index.
+// Only silk v3 file(with or without STX), Ogg/silk and headerless streams(WithoutHeader, WithPacketLengths) are supported.
+// 读取一遍数据流，建立索引, 只支持 silk v3 文件, Ogg 封装和没有文件头的流
+func BuildIndex(src io.Reader, opts ...DecodeOpt) (*Index, error) {
+	var cfg = buildDecodeCfg(opts...)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return buildIndex(src, cfg)
+}
+
+func buildIndex(src io.Reader, cfg *DecodeCfg) (*Index, error) {
+	var (
+		counter = &countingReader{reader: src}
+		reader  = bufio.NewReader(counter)
+		// 已经被读取器消费的字节数
+		offset = func() int64 { return counter.n - int64(reader.Buffered()) }
+	)
+	packets, err := openSeekablePackets(reader, cfg)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		index  = &Index{SampleRate: cfg.SampleRate}
+		frames = 1 // 丢包时按上一个包的帧数计算
+	)
+	for {
+		var entry = IndexEntry{Offset: offset(), Sample: index.Samples}
+		packet, err := packets.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ogg, ok := packets.(*oggReader); ok { // Ogg 只能定位到页, 记录包开始的那一页
+			entry.Offset = ogg.start
+		}
+		if n := packetFrames(packet); n > 0 {
+			frames = n
+		}
+		index.Packets = append(index.Packets, entry)
+		index.Samples += int64(frames * FRAME_LENGTH_MS * cfg.SampleRate / 1000)
+	}
+	log("index: packets=%d, samples=%d", len(index.Packets), index.Samples)
+	return index, nil
+}
+
+// openSeekablePackets opens the formats whose packets can be located by byte offset.
+// 打开可以按字节偏移定位数据包的格式
+func openSeekablePackets(reader *bufio.Reader, cfg *DecodeCfg) (packetReader, error) {
+	if cfg.PacketLengths == nil && !cfg.NoHeader {
+		head, _ := reader.Peek(sniffLen)
+		if format := DetectFormat(head); format != FormatSilk && format != FormatSilkStx && format != FormatOggSilk {
+			return nil, fmt.Errorf("seeking is not supported: %w", newFormatError(format, head))
+		}
+	}
+	return openPackets(reader, cfg)
+}
+
+// packetFrames returns the number of 20ms frames in the packet, 0 if the packet is lost or corrupt.
+func packetFrames(packet []byte) int {
+	toc, ok := readTOC(packet)
+	if !ok {
+		return 0
+	}
+	return int(toc.framesInPacket)
+}
+
+// readTOC reads the table of contents of the packet, ok is false if the packet is lost or corrupt.
+func readTOC(packet []byte) (toc C.SKP_Silk_TOC_struct, ok bool) {
+	if len(packet) == 0 {
+		return toc, false
+	}
+	// void SKP_Silk_SDK_get_TOC(
+	//     const SKP_uint8     *inData,    /* I:   Encoded input vector     */
+	//     const SKP_int       nBytesIn,   /* I:   Number of input bytes    */
+	//     SKP_Silk_TOC_struct *Silk_TOC   /* O:   Table of contents        */
+	// );
+	C.SKP_Silk_SDK_get_TOC((*C.SKP_uint8)(unsafe.Pointer(&packet[0])), C.SKP_int(len(packet)), &toc)
+	return toc, toc.corrupt == 0
+}
+
+// entry returns the index of the packet which contains the sample.
+func (idx *Index) entry(sample int64) int {
+	var lo, hi = 0, len(idx.Packets) // 找到最后一个 Sample <= sample 的包
+	for lo+1 < hi {
+		var mid = (lo + hi) / 2
+		if idx.Packets[mid].Sample <= sample {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// MarshalBinary encodes the index, so it can be cached.
+// 序列化, 格式: "SKIX" + 版本号 + 采样率 + 包数量 + 每个包的(偏移增量, 采样增量) + 最后一个包的采样数, 整数均为 uvarint
+func (idx *Index) MarshalBinary() ([]byte, error) {
+	var buf = make([]byte, 0, len(indexMagic)+1+len(idx.Packets)*3+16)
+	buf = append(buf, indexMagic...)
+	buf = append(buf, indexVersion)
+	buf = binary.AppendUvarint(buf, uint64(idx.SampleRate))
+	buf = binary.AppendUvarint(buf, uint64(len(idx.Packets)))
+	var prev IndexEntry
+	for _, e := range idx.Packets {
+		if e.Offset < prev.Offset || e.Sample < prev.Sample {
+			return nil, fmt.Errorf("invalid index, packets are not in order")
+		}
+		buf = binary.AppendUvarint(buf, uint64(e.Offset-prev.Offset))
+		buf = binary.AppendUvarint(buf, uint64(e.Sample-prev.Sample))
+		prev = e
+	}
+	if idx.Samples < prev.Sample {
+		return nil, fmt.Errorf("invalid index, total samples %d < %d", idx.Samples, prev.Sample)
+	}
+	return binary.AppendUvarint(buf, uint64(idx.Samples-prev.Sample)), nil
+}
+
+// UnmarshalBinary decodes the index encoded by MarshalBinary.
+// 反序列化
+func (idx *Index) UnmarshalBinary(data []byte) error {
+	if len(data) < len(indexMagic)+1 || string(data[:len(indexMagic)]) != indexMagic {
+		return fmt.Errorf("invalid index data")
+	}
+	if v := data[len(indexMagic)]; v != indexVersion {
+		return fmt.Errorf("unsupported index version: %d", v)
+	}
+	data = data[len(indexMagic)+1:]
+	var err error
+	next := func() int64 {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			err = fmt.Errorf("invalid index data, truncated")
+			return 0
+		}
+		data = data[n:]
+		return int64(v)
+	}
+	var sampleRate, count = next(), next()
+	if err != nil {
+		return err
+	}
+	if count > int64(len(data)) { // 每个包至少 2 字节
+		return fmt.Errorf("invalid index data, packet count=%d", count)
+	}
+	var (
+		packets = make([]IndexEntry, 0, count)
+		prev    IndexEntry
+	)
+	for i := int64(0); i < count && err == nil; i++ {
+		prev = IndexEntry{Offset: prev.Offset + next(), Sample: prev.Sample + next()}
+		packets = append(packets, prev)
+	}
+	var samples = prev.Sample + next()
+	if err != nil {
+		return err
+	}
+	idx.SampleRate, idx.Samples, idx.Packets = int(sampleRate), samples, packets
+	return nil
+}
+
+// countingReader counts the bytes read from the reader.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written to the writer.
+type countingWriter struct {
+	writer io.Writer
+	n      int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,451 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// 响度标准化
+// 整段数据可用时(Decode/Encode)使用两遍处理: 先测量响度(EBU R128 / ITU-R BS.1770 积分响度), 再统一调整增益,
+// 增益受真峰值上限限制; 流式接口(Decoder)使用自动增益控制(AGC).
+
+// NormalizeMode is the loudness measurement used by normalization.
+// 响度标准化的测量方式
+type NormalizeMode int
+
+const (
+	NormalizeLUFS NormalizeMode = iota // EBU R128 integrated loudness, target in LUFS
+	NormalizeRMS                       // RMS level, target in dBFS
+	NormalizePeak                      // sample peak, target in dBFS
+)
+
+const (
+	defaultTruePeak = -1.0  // 默认真峰值上限 -1 dBTP
+	absoluteGate    = -70.0 // BS.1770 绝对门限 LUFS
+	relativeGate    = -10.0 // BS.1770 相对门限 LU
+	maxNormalizeDb  = 30.0  // 最大增益, 避免放大噪声
+)
+
+// NormalizeCfg is the setting of loudness normalization.
+// 响度标准化设置
+type NormalizeCfg struct {
+	Mode     NormalizeMode
+	Target   float64 // target loudness, LUFS for NormalizeLUFS, dBFS for others
+	TruePeak float64 // true peak ceiling in dBTP, 0 means -1 dBTP
+}
+
+func (c *NormalizeCfg) ceiling() float64 {
+	if c.TruePeak == 0 {
+		return defaultTruePeak
+	}
+	return c.TruePeak
+}
+
+// normalizePCM measures the whole 16bit pcm and applies a constant gain in place.
+// 两遍处理: 测量整段 pcm 的响度, 然后调整增益
+func normalizePCM(pcm []byte, sampleRate int, cfg *NormalizeCfg) {
+	var samples = pcmToFloat(pcm)
+	if len(samples) == 0 || sampleRate <= 0 {
+		return
+	}
+	var meter = newLoudnessMeter(cfg.Mode, sampleRate)
+	for _, s := range samples {
+		meter.add(s)
+	}
+	if gain, ok := meter.gain(cfg); ok {
+		applyGain(pcm, samples, math.Pow(10, gain/20))
+	}
+}
+
+// MeasureNormalizeGain decodes the stream and measures the loudness of the whole output like Decode with Normalize,
+// returns the gain(dB) Decode would apply, so the stream can be decoded again by a Decoder with the constant Gain.
+// It only keeps the measurement in memory, not the pcm.
+// 两遍处理的第一遍: 解码并测量整段响度, 返回 Decode 会使用的增益(dB), 第二遍可以使用 Gain 选项流式解码;
+// 只保存测量结果, 不保存 pcm
+func MeasureNormalizeGain(src io.Reader, opts ...DecodeOpt) (float64, error) {
+	var cfg = buildDecodeCfg(opts...)
+	if err := cfg.Validate(); err != nil {
+		return 0, err
+	}
+	if cfg.Normalize == nil {
+		return 0, fmt.Errorf("normalize is not set")
+	}
+	var normalize = cfg.Normalize
+	cfg.Normalize, cfg.Channels, cfg.PCMBigEndian = nil, 1, false // 测量单声道小端序输出
+	d, err := newDecoder(src, cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+	var meter = newLoudnessMeter(normalize.Mode, cfg.SampleRate)
+	if _, err = io.Copy(meter, d); err != nil {
+		return 0, err
+	}
+	gain, _ := meter.gain(normalize)
+	return gain, nil
+}
+
+func pcmToFloat(pcm []byte) []float64 {
+	var samples = make([]float64, len(pcm)/2)
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:]))) / 32768
+	}
+	return samples
+}
+
+func applyGain(pcm []byte, samples []float64, gain float64) {
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(toInt16(s*gain)))
+	}
+}
+
+func toInt16(s float64) int16 {
+	var v = math.Round(s * 32768)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+func toDb(power float64) float64 {
+	return 10 * math.Log10(power)
+}
+
+func rmsLevel(samples []float64) float64 {
+	var meter = newLoudnessMeter(NormalizeRMS, 0)
+	for _, s := range samples {
+		meter.add(s)
+	}
+	return meter.level()
+}
+
+func peakLevel(samples []float64) float64 {
+	var meter = newLoudnessMeter(NormalizePeak, 0)
+	for _, s := range samples {
+		meter.add(s)
+	}
+	return meter.level()
+}
+
+// loudnessMeter measures the level and the true peak of samples added one by one,
+// the result is the same as measuring the whole signal at once.
+// 逐个采样点测量响度和真峰值, 结果与一次测量整段信号相同
+type loudnessMeter struct {
+	mode     NormalizeMode
+	filter   *kWeighting // LUFS 使用
+	step     int         // 100ms 的采样点数
+	block    float64     // 当前 100ms 的 K 加权能量和
+	power    []float64   // 每 100ms 的 K 加权能量和
+	square   float64     // 能量和
+	peak     float64     // 采样峰值
+	truePeak truePeakMeter
+	n        int    // 已测量的采样点数
+	pending  []byte // Write 时不完整的采样点
+}
+
+func newLoudnessMeter(mode NormalizeMode, sampleRate int) *loudnessMeter {
+	var m = &loudnessMeter{mode: mode, step: sampleRate / 10}
+	if mode == NormalizeLUFS {
+		m.filter = newKWeighting(sampleRate)
+	}
+	return m
+}
+
+func (m *loudnessMeter) add(s float64) {
+	if m.filter != nil {
+		var y = m.filter.process(s)
+		m.block += y * y
+		if (m.n+1)%m.step == 0 {
+			m.power = append(m.power, m.block)
+			m.block = 0
+		}
+	}
+	m.square += s * s
+	m.peak = math.Max(m.peak, math.Abs(s))
+	m.truePeak.add(s)
+	m.n++
+}
+
+// Write measures 16bit little endian pcm, a sample may be split between two writes.
+func (m *loudnessMeter) Write(p []byte) (int, error) {
+	var n = len(p)
+	if len(m.pending) > 0 && len(p) > 0 {
+		m.add(float64(int16(uint16(m.pending[0])|uint16(p[0])<<8)) / 32768)
+		m.pending, p = m.pending[:0], p[1:]
+	}
+	for ; len(p) >= 2; p = p[2:] {
+		m.add(float64(int16(binary.LittleEndian.Uint16(p))) / 32768)
+	}
+	m.pending = append(m.pending, p...)
+	return n, nil
+}
+
+// level returns the measured level in the mode, -Inf if all samples are 0.
+func (m *loudnessMeter) level() float64 {
+	switch m.mode {
+	case NormalizeLUFS:
+		if level, ok := m.integrated(); ok {
+			return level
+		}
+		// 太短或全部是静音时无法测量积分响度, 退化为 RMS
+		return toDb(m.square / float64(m.n))
+	case NormalizeRMS:
+		return toDb(m.square / float64(m.n))
+	default:
+		return 20 * math.Log10(m.peak)
+	}
+}
+
+// gain returns the gain(dB) to normalize the measured signal, ok is false if it should be kept as is.
+func (m *loudnessMeter) gain(cfg *NormalizeCfg) (gain float64, ok bool) {
+	if m.n == 0 {
+		return 0, false
+	}
+	var level = m.level()
+	if math.IsInf(level, -1) { // 全部是 0
+		return 0, false
+	}
+	gain = cfg.Target - level
+	if gain > maxNormalizeDb {
+		gain = maxNormalizeDb
+	}
+	// 增益不能使真峰值超过上限
+	if peak := m.truePeak.level(); peak+gain > cfg.ceiling() {
+		gain = cfg.ceiling() - peak
+	}
+	log("normalize: mode=%d, level=%.2f, gain=%.2fdB", cfg.Mode, level, gain)
+	return gain, true
+}
+
+// -------------------- BS.1770 --------------------
+
+// biquad is a second order IIR filter.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	var y = f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting is the K-weighting filter of BS.1770: a high shelf pre-filter and a high pass(RLB) filter.
+// The coefficients are derived for any sample rate(the same as libebur128).
+type kWeighting struct {
+	shelf, highPass biquad
+}
+
+func newKWeighting(sampleRate int) *kWeighting {
+	var fs = float64(sampleRate)
+	var k = &kWeighting{}
+	{
+		const f0, g, q = 1681.974450955533, 3.999843853973347, 0.7071752369554196
+		var K = math.Tan(math.Pi * f0 / fs)
+		var vh = math.Pow(10, g/20)
+		var vb = math.Pow(vh, 0.4996667741545416)
+		var a0 = 1 + K/q + K*K
+		k.shelf = biquad{
+			b0: (vh + vb*K/q + K*K) / a0,
+			b1: 2 * (K*K - vh) / a0,
+			b2: (vh - vb*K/q + K*K) / a0,
+			a1: 2 * (K*K - 1) / a0,
+			a2: (1 - K/q + K*K) / a0,
+		}
+	}
+	{
+		const f0, q = 38.13547087602444, 0.5003270373238773
+		var K = math.Tan(math.Pi * f0 / fs)
+		var a0 = 1 + K/q + K*K
+		k.highPass = biquad{
+			b0: 1, b1: -2, b2: 1,
+			a1: 2 * (K*K - 1) / a0,
+			a2: (1 - K/q + K*K) / a0,
+		}
+	}
+	return k
+}
+
+func (k *kWeighting) process(x float64) float64 {
+	return k.highPass.process(k.shelf.process(x))
+}
+
+// integratedLoudness measures the gated integrated loudness(LUFS) of mono samples,
+// ok is false when there is no block above the gates.
+func integratedLoudness(samples []float64, sampleRate int) (lufs float64, ok bool) {
+	var meter = newLoudnessMeter(NormalizeLUFS, sampleRate)
+	for _, s := range samples {
+		meter.add(s)
+	}
+	return meter.integrated()
+}
+
+// integrated returns the gated integrated loudness(LUFS), ok is false when there is no block above the gates.
+// 积分响度: 400ms 块, 75% 重叠, 绝对门限 -70 LUFS, 相对门限 -10 LU
+func (m *loudnessMeter) integrated() (lufs float64, ok bool) {
+	var blocks []float64 // 每个 400ms 块的均方值
+	for i := 0; i+4 <= len(m.power); i++ {
+		blocks = append(blocks, (m.power[i]+m.power[i+1]+m.power[i+2]+m.power[i+3])/float64(4*m.step))
+	}
+	loudness := func(ms float64) float64 { return -0.691 + toDb(ms) }
+	gated := func(threshold float64) (mean float64, n int) {
+		for _, b := range blocks {
+			if loudness(b) > threshold {
+				mean += b
+				n++
+			}
+		}
+		if n > 0 {
+			mean /= float64(n)
+		}
+		return mean, n
+	}
+	mean, n := gated(absoluteGate)
+	if n == 0 {
+		return 0, false
+	}
+	mean, n = gated(loudness(mean) + relativeGate)
+	if n == 0 {
+		return 0, false
+	}
+	return loudness(mean), true
+}
+
+const (
+	truePeakFactor = 4  // 过采样倍数
+	truePeakTaps   = 12 // 每个相位的抽头数
+)
+
+// truePeakCoef is the windowed sinc interpolation filter of every oversampling phase.
+var truePeakCoef = func() (coef [truePeakFactor - 1][truePeakTaps]float64) {
+	for phase := 1; phase < truePeakFactor; phase++ {
+		for j := range coef[phase-1] {
+			var x = float64(j-truePeakTaps/2+1) - float64(phase)/truePeakFactor
+			var w = 0.5 + 0.5*math.Cos(math.Pi*x/(truePeakTaps/2)) // Hann 窗
+			coef[phase-1][j] = w * math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+	}
+	return
+}()
+
+// truePeakLevel estimates the true peak(dBTP) by 4x oversampling, as BS.1770 annex 2.
+// 4 倍过采样估算真峰值
+func truePeakLevel(samples []float64) float64 {
+	var meter truePeakMeter
+	for _, s := range samples {
+		meter.add(s)
+	}
+	return meter.level()
+}
+
+// truePeakMeter estimates the true peak of samples added one by one.
+type truePeakMeter struct {
+	history [truePeakTaps]float64 // 最近的采样点
+	n       int
+	peak    float64
+}
+
+func (m *truePeakMeter) add(s float64) {
+	m.peak = math.Max(m.peak, math.Abs(s))
+	copy(m.history[:], m.history[1:])
+	m.history[truePeakTaps-1] = s
+	if m.n++; m.n < truePeakTaps {
+		return
+	}
+	for _, coef := range truePeakCoef {
+		var v float64
+		for j, c := range coef {
+			v += c * m.history[j]
+		}
+		m.peak = math.Max(m.peak, math.Abs(v))
+	}
+}
+
+func (m *truePeakMeter) level() float64 {
+	return 20 * math.Log10(m.peak)
+}
+
+// -------------------- AGC --------------------
+
+// agc is the automatic gain control used by streaming APIs, it processes 16bit pcm frame by frame:
+// the loudness is measured over about 3 seconds, the gain changes smoothly and is held in silence,
+// samples above the true peak ceiling are clipped.
+// 流式接口使用的自动增益控制: 约 3 秒窗口测量响度, 增益平滑变化, 静音时保持增益不变, 超过上限的采样点削波
+type agc struct {
+	cfg        *NormalizeCfg
+	filter     *kWeighting
+	sampleRate int
+	power      float64 // 能量(或峰值包络)的指数平均
+	gain       float64 // 当前线性增益
+	ceiling    float64 // 线性上限
+}
+
+const (
+	agcWindow = 3.0 // 测量窗口, 秒
+	agcSmooth = 0.5 // 增益变化的时间常数, 秒
+)
+
+func newAGC(cfg *NormalizeCfg, sampleRate int) *agc {
+	var a = &agc{cfg: cfg, sampleRate: sampleRate, gain: 1, ceiling: math.Pow(10, cfg.ceiling()/20)}
+	if cfg.Mode == NormalizeLUFS {
+		a.filter = newKWeighting(sampleRate)
+	}
+	return a
+}
+
+// process applies the gain to the pcm frame in place.
+func (a *agc) process(pcm []byte) {
+	var samples = pcmToFloat(pcm)
+	if len(samples) == 0 {
+		return
+	}
+	var (
+		duration = float64(len(samples)) / float64(a.sampleRate)
+		measure  float64
+	)
+	for _, s := range samples {
+		switch a.cfg.Mode {
+		case NormalizeLUFS:
+			var y = a.filter.process(s)
+			measure += y * y
+		case NormalizeRMS:
+			measure += s * s
+		default:
+			measure = math.Max(measure, s*s)
+		}
+	}
+	if a.cfg.Mode != NormalizePeak {
+		measure /= float64(len(samples))
+	}
+	var level = toDb(measure)
+	if a.cfg.Mode == NormalizeLUFS {
+		level -= 0.691
+	}
+	if level > absoluteGate { // 静音时不更新
+		if a.power == 0 {
+			a.power = measure
+		} else if a.cfg.Mode == NormalizePeak && measure > a.power {
+			a.power = measure // 峰值包络: 立即上升, 缓慢下降
+		} else {
+			var alpha = duration / agcWindow
+			a.power += (measure - a.power) * alpha
+		}
+		var current = toDb(a.power)
+		if a.cfg.Mode == NormalizeLUFS {
+			current -= 0.691
+		}
+		var target = math.Pow(10, math.Min(a.cfg.Target-current, maxNormalizeDb)/20)
+		a.gain += (target - a.gain) * math.Min(duration/agcSmooth, 1)
+	}
+	for i, s := range samples {
+		samples[i] = math.Max(-a.ceiling, math.Min(a.ceiling, s*a.gain))
+	}
+	applyGain(pcm, samples, 1)
+}
@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+// sinePCM returns seconds of 16bit pcm of a 1kHz sine wave.
+func sinePCM(sampleRate int, seconds, amplitude float64) []byte {
+	var pcm []byte
+	for i := 0; i < int(seconds*float64(sampleRate)); i++ {
+		var s = amplitude * math.Sin(2*math.Pi*1000*float64(i)/float64(sampleRate))
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(toInt16(s)))
+	}
+	return pcm
+}
+
+func TestIntegratedLoudness(t *testing.T) {
+	// BS.1770: 0 dBFS 的 1kHz 正弦波为 -3.01 LUFS
+	for _, rate := range []int{48000, 24000, 16000} {
+		for _, amplitude := range []float64{1, 0.1} {
+			got, ok := integratedLoudness(pcmToFloat(sinePCM(rate, 3, amplitude)), rate)
+			if want := -3.01 + 20*math.Log10(amplitude); !ok || math.Abs(got-want) > 0.1 {
+				t.Errorf("rate=%d, amplitude=%v: loudness=%.2f(%v), want %.2f", rate, amplitude, got, ok, want)
+			}
+		}
+	}
+	if _, ok := integratedLoudness(make([]float64, 48000), 48000); ok {
+		t.Errorf("silence should be gated")
+	}
+	if _, ok := integratedLoudness(make([]float64, 100), 48000); ok {
+		t.Errorf("too short input should not be measured")
+	}
+}
+
+func TestNormalizePCM(t *testing.T) {
+	const rate = 24000
+	var pcm = sinePCM(rate, 3, 0.05)
+	normalizePCM(pcm, rate, &NormalizeCfg{Mode: NormalizeLUFS, Target: -16})
+	if got, _ := integratedLoudness(pcmToFloat(pcm), rate); math.Abs(got+16) > 0.1 {
+		t.Errorf("loudness=%.2f, want -16", got)
+	}
+
+	// 增益受真峰值上限限制
+	pcm = sinePCM(rate, 3, 0.05)
+	normalizePCM(pcm, rate, &NormalizeCfg{Mode: NormalizeLUFS, Target: 0, TruePeak: -3})
+	if got := truePeakLevel(pcmToFloat(pcm)); math.Abs(got+3) > 0.1 {
+		t.Errorf("true peak=%.2f, want -3", got)
+	}
+
+	// 太短时按 RMS 计算
+	pcm = sinePCM(rate, 0.2, 0.05)
+	normalizePCM(pcm, rate, &NormalizeCfg{Mode: NormalizeLUFS, Target: -20})
+	if got := rmsLevel(pcmToFloat(pcm)); math.Abs(got+20) > 0.1 {
+		t.Errorf("rms=%.2f, want -20", got)
+	}
+
+	pcm = sinePCM(rate, 1, 0.05)
+	normalizePCM(pcm, rate, &NormalizeCfg{Mode: NormalizePeak, Target: -6})
+	if got := peakLevel(pcmToFloat(pcm)); math.Abs(got+6) > 0.1 {
+		t.Errorf("peak=%.2f, want -6", got)
+	}
+
+	// 静音不变
+	pcm = make([]byte, 4800)
+	normalizePCM(pcm, rate, &NormalizeCfg{Mode: NormalizeLUFS, Target: -16})
+	if !bytes.Equal(pcm, make([]byte, 4800)) {
+		t.Errorf("silence should be kept")
+	}
+}
+
+func TestAGC(t *testing.T) {
+	const rate = 24000
+	var (
+		pcm   = sinePCM(rate, 10, 0.02)
+		agc   = newAGC(&NormalizeCfg{Mode: NormalizeLUFS, Target: -16}, rate)
+		frame = FRAME_LENGTH_MS * rate / 1000 * 2
+	)
+	for i := 0; i < len(pcm); i += frame {
+		agc.process(pcm[i : i+frame])
+	}
+	// 增益收敛后接近目标响度
+	if got, _ := integratedLoudness(pcmToFloat(pcm[len(pcm)-rate*2*2:]), rate); math.Abs(got+16) > 0.5 {
+		t.Errorf("loudness=%.2f, want -16", got)
+	}
+}
+
+func TestDecodeNormalize(t *testing.T) {
+	silk, err := Encode(bytes.NewReader(sinePCM(defaultSampleRate, 3, 0.05)),
+		func(ec *EncodeCfg) { ec.Normalize = &NormalizeCfg{Mode: NormalizeLUFS, Target: -16} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm, err := Decode(bytes.NewReader(silk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := integratedLoudness(pcmToFloat(pcm), defaultSampleRate); math.Abs(got+16) > 1 {
+		t.Errorf("encode normalize: loudness=%.2f, want -16", got)
+	}
+
+	pcm, err = Decode(bytes.NewReader(silk), func(dc *DecodeCfg) { dc.Normalize = &NormalizeCfg{Mode: NormalizeLUFS, Target: -23} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := integratedLoudness(pcmToFloat(pcm), defaultSampleRate); math.Abs(got+23) > 0.1 {
+		t.Errorf("decode normalize: loudness=%.2f, want -23", got)
+	}
+
+	d, err := NewDecoder(bytes.NewReader(silk), func(dc *DecodeCfg) { dc.Normalize = &NormalizeCfg{Mode: NormalizeLUFS, Target: -23} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if pcm, err = io.ReadAll(d); err != nil {
+		t.Fatal(err)
+	}
+	if len(pcm) != 3*defaultSampleRate*2 {
+		t.Errorf("streaming decode: got %d bytes", len(pcm))
+	}
+}
+
+func TestMeasureNormalizeGain(t *testing.T) {
+	silk, err := Encode(bytes.NewReader(sinePCM(defaultSampleRate, 3, 0.05)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var normalize = func(dc *DecodeCfg) { dc.Normalize = &NormalizeCfg{Mode: NormalizeLUFS, Target: -10} }
+	want, err := Decode(bytes.NewReader(silk), normalize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 两遍流式处理: 先测量增益, 再以固定增益解码, 结果与 Decode 完全相同
+	gain, err := MeasureNormalizeGain(bytes.NewReader(silk), normalize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDecoder(bytes.NewReader(silk), func(dc *DecodeCfg) { dc.Gain = gain })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	got, err := io.ReadAll(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("two-pass streaming decode differs from Decode, gain=%.2fdB", gain)
+	}
+	if _, err = NewDecoder(bytes.NewReader(silk), normalize, func(dc *DecodeCfg) { dc.Gain = gain }); err == nil {
+		t.Errorf("expected error when Gain is used with Normalize")
+	}
+
+	// 按奇数字节分块写入与逐个采样点测量相同
+	var (
+		pcm   = sinePCM(8000, 1, 0.3)
+		meter = newLoudnessMeter(NormalizeLUFS, 8000)
+		whole = newLoudnessMeter(NormalizeLUFS, 8000)
+	)
+	for chunk := pcm; len(chunk) > 0; {
+		var n = 333
+		if n > len(chunk) {
+			n = len(chunk)
+		}
+		meter.Write(chunk[:n])
+		chunk = chunk[n:]
+	}
+	for _, s := range pcmToFloat(pcm) {
+		whole.add(s)
+	}
+	if meter.level() != whole.level() || meter.truePeak.level() != whole.truePeak.level() {
+		t.Errorf("Write: level=%v, true peak=%v, want %v, %v",
+			meter.level(), meter.truePeak.level(), whole.level(), whole.truePeak.level())
+	}
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MP3 编码: 调用 libmp3lame, 需要使用 -tags lame 编译(见 mp3_lame.go), 否则返回 ErrMP3Unsupported.
+// ID3v2 标签由纯 Go 代码写入(见 id3.go).
+
+// ErrMP3Unsupported is returned when built without lame tag.
+var ErrMP3Unsupported = errors.New("mp3 is not supported: built without lame tag(needs libmp3lame)")
+
+var mp3SampleRates = []int{8000, 11025, 12000, 16000, 22050, 24000, 32000, 44100, 48000}
+
+// MP3Cfg is the settings of mp3 encoding.
+type MP3Cfg struct {
+	SampleRate    int         // sample rate of input pcm, DecodeToMP3 uses the decode sample rate
+	Channels      int         // number of channels of input pcm(1 or 2), DecodeToMP3 uses the decode channels
+	Bitrate       int         // CBR bitrate in kbps, 0 means LAME default(128), ignored in VBR mode
+	VBR           int         // VBR quality 0(best) - 9(smallest), -1 means CBR
+	Quality       int         // algorithm quality 0(best, slowest) - 9(fastest), -1 means LAME default
+	OutSampleRate int         // sample rate of mp3, 0 means chosen by LAME
+	ID3           *ID3Tags    // ID3v2 tags written before the mp3 frames, nil means no tag
+	Decode        []DecodeOpt // decode options used by DecodeToMP3
+}
+
+type MP3Opt func(*MP3Cfg)
+
+func buildMP3Cfg(opts ...MP3Opt) *MP3Cfg {
+	var cfg = &MP3Cfg{
+		SampleRate: defaultSampleRate,
+		Channels:   1,
+		VBR:        -1,
+		Quality:    -1,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// Validate checks all fields of the mp3 config, and returns an error listing every invalid field.
+// 检查 mp3 编码参数, 返回的错误中包含所有无效的参数
+func (cfg *MP3Cfg) Validate() error {
+	var errs []error
+	if cfg.SampleRate <= 0 {
+		errs = append(errs, fmt.Errorf("invalid SampleRate %d", cfg.SampleRate))
+	}
+	if cfg.Channels != 1 && cfg.Channels != 2 {
+		errs = append(errs, fmt.Errorf("invalid Channels %d, mp3 supports 1 or 2 channels", cfg.Channels))
+	}
+	if cfg.Bitrate != 0 && (cfg.Bitrate < 8 || cfg.Bitrate > 320) {
+		errs = append(errs, fmt.Errorf("invalid Bitrate %d, valid range 8 - 320 kbps", cfg.Bitrate))
+	}
+	if cfg.VBR < -1 || cfg.VBR > 9 {
+		errs = append(errs, fmt.Errorf("invalid VBR %d, valid range 0 - 9, or -1 means CBR", cfg.VBR))
+	}
+	if cfg.Quality < -1 || cfg.Quality > 9 {
+		errs = append(errs, fmt.Errorf("invalid Quality %d, valid range 0 - 9, or -1 means default", cfg.Quality))
+	}
+	if cfg.OutSampleRate != 0 && !contains(mp3SampleRates, cfg.OutSampleRate) {
+		errs = append(errs, fmt.Errorf("invalid OutSampleRate %d, should be one of %v", cfg.OutSampleRate, mp3SampleRates))
+	}
+	return errors.Join(errs...)
+}
+
+// NewMP3Writer returns a writer which encodes 16bit little endian pcm to mp3 and writes to w.
+// Close flushes the encoder but does not close w.
+// 创建 mp3 编码器: 写入 16 位小端序 pcm, 编码为 mp3 后写入 w; Close 不会关闭 w
+func NewMP3Writer(w io.Writer, opts ...MP3Opt) (io.WriteCloser, error) {
+	var cfg = buildMP3Cfg(opts...)
+	return newMP3Writer(w, cfg)
+}
+
+func newMP3Writer(w io.Writer, cfg *MP3Cfg) (io.WriteCloser, error) {
+	if !MP3Supported {
+		return nil, ErrMP3Unsupported
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	log("mp3 options: %#v", cfg)
+	if cfg.ID3 != nil {
+		if _, err := w.Write(cfg.ID3.marshal()); err != nil {
+			return nil, fmt.Errorf("failed to write id3 tag: %w", err)
+		}
+	}
+	return newLameWriter(w, cfg)
+}
+
+// DecodeToMP3 decodes the silk stream src and encodes the pcm to mp3, block by block.
+// 将 silk 流解码并编码为 mp3, 逐块处理, 不需要保存整个 pcm
+func DecodeToMP3(src io.Reader, w io.Writer, opts ...MP3Opt) error {
+	var cfg = buildMP3Cfg(opts...)
+	var decodeCfg = buildDecodeCfg(cfg.Decode...)
+	cfg.SampleRate, cfg.Channels = decodeCfg.SampleRate, 1
+	if decodeCfg.Channels > 1 {
+		cfg.Channels = decodeCfg.Channels
+	}
+	// mp3 编码器需要小端序输入
+	var decodeOpts = append(cfg.Decode[:len(cfg.Decode):len(cfg.Decode)], func(dc *DecodeCfg) { dc.PCMBigEndian = false })
+	dec, err := NewDecoder(src, decodeOpts...)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+	enc, err := newMP3Writer(w, cfg)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(enc, dec); err != nil {
+		enc.Close()
+		return err
+	}
+	return enc.Close()
+}
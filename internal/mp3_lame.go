@@ -0,0 +1,109 @@
+//go:build lame
+
+package internal
+
+/*
+#cgo LDFLAGS: -lmp3lame
+#include <lame/lame.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// MP3Supported is true when built with lame tag.
+// 使用 -tags lame 编译时支持 mp3 编码
+const MP3Supported = true
+
+// newLameWriter creates a LAME encoder with the validated config.
+func newLameWriter(w io.Writer, cfg *MP3Cfg) (io.WriteCloser, error) {
+	var gfp = C.lame_init()
+	if gfp == nil {
+		return nil, errors.New("failed to create mp3 encoder")
+	}
+	C.lame_set_in_samplerate(gfp, C.int(cfg.SampleRate))
+	C.lame_set_num_channels(gfp, C.int(cfg.Channels))
+	if cfg.VBR >= 0 {
+		C.lame_set_VBR(gfp, C.vbr_default)
+		C.lame_set_VBR_quality(gfp, C.float(cfg.VBR))
+	} else if cfg.Bitrate > 0 {
+		C.lame_set_brate(gfp, C.int(cfg.Bitrate))
+	}
+	if cfg.Quality >= 0 {
+		C.lame_set_quality(gfp, C.int(cfg.Quality))
+	}
+	if cfg.OutSampleRate > 0 {
+		C.lame_set_out_samplerate(gfp, C.int(cfg.OutSampleRate))
+	}
+	if code := C.lame_init_params(gfp); code < 0 {
+		C.lame_close(gfp)
+		return nil, fmt.Errorf("failed to create mp3 encoder: lame_init_params: %d", int(code))
+	}
+	return &mp3Writer{w: w, gfp: gfp, channels: cfg.Channels}, nil
+}
+
+type mp3Writer struct {
+	w        io.Writer
+	gfp      *C.lame_global_flags
+	channels int
+	rest     []byte // 不足一个采样点(所有声道)的数据
+	buf      []byte
+}
+
+func (mw *mp3Writer) Write(p []byte) (int, error) {
+	var (
+		pcm        = append(mw.rest, p...)
+		frameBytes = mw.channels * 2
+		samples    = len(pcm) / frameBytes // 每个声道的采样点数
+	)
+	if samples > 0 {
+		// mp3 缓冲区最大需要 1.25*samples + 7200 字节
+		mw.grow(samples*5/4 + 7200)
+		var (
+			in   = (*C.short)(unsafe.Pointer(&pcm[0]))
+			out  = (*C.uchar)(unsafe.Pointer(&mw.buf[0]))
+			size C.int
+		)
+		if mw.channels == 1 {
+			size = C.lame_encode_buffer(mw.gfp, in, in, C.int(samples), out, C.int(len(mw.buf)))
+		} else {
+			size = C.lame_encode_buffer_interleaved(mw.gfp, in, C.int(samples), out, C.int(len(mw.buf)))
+		}
+		if size < 0 {
+			return 0, fmt.Errorf("lame_encode_buffer: %d", int(size))
+		}
+		if _, err := mw.w.Write(mw.buf[:size]); err != nil {
+			return 0, err
+		}
+	}
+	mw.rest = append(mw.rest[:0], pcm[samples*frameBytes:]...)
+	return len(p), nil
+}
+
+func (mw *mp3Writer) grow(n int) {
+	if len(mw.buf) < n {
+		mw.buf = make([]byte, n)
+	}
+}
+
+// Close flushes the encoder and frees it.
+func (mw *mp3Writer) Close() error {
+	if mw.gfp == nil {
+		return nil
+	}
+	defer func() {
+		C.lame_close(mw.gfp)
+		mw.gfp = nil
+	}()
+	mw.grow(7200)
+	var size = C.lame_encode_flush(mw.gfp, (*C.uchar)(unsafe.Pointer(&mw.buf[0])), C.int(len(mw.buf)))
+	if size < 0 {
+		return fmt.Errorf("lame_encode_flush: %d", int(size))
+	}
+	_, err := mw.w.Write(mw.buf[:size])
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !lame
+
+package internal
+
+import "io"
+
+// MP3Supported is false when built without lame tag.
+// 没有使用 -tags lame 编译时不支持 mp3 编码
+const MP3Supported = false
+
+func newLameWriter(io.Writer, *MP3Cfg) (io.WriteCloser, error) {
+	return nil, ErrMP3Unsupported
+}
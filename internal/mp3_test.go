@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestID3Tags(t *testing.T) {
+	var tag = (&ID3Tags{Title: "hao", Artist: "张三", Comment: "voice"}).marshal()
+	if string(tag[:5]) != "ID3\x03\x00" {
+		t.Fatalf("invalid header %x", tag[:10])
+	}
+	var size = int(tag[6])<<21 | int(tag[7])<<14 | int(tag[8])<<7 | int(tag[9])
+	if size != len(tag)-10 {
+		t.Fatalf("size=%d, want %d", size, len(tag)-10)
+	}
+	var frames = map[string][]byte{}
+	for data := tag[10:]; len(data) > 0; {
+		var n = int(binary.BigEndian.Uint32(data[4:]))
+		frames[string(data[:4])] = data[10 : 10+n]
+		data = data[10+n:]
+	}
+	if got := string(frames["TIT2"]); got != "\x00hao" {
+		t.Errorf("TIT2=%q", got)
+	}
+	var artist = []byte{1, 0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune("张三")) {
+		artist = binary.LittleEndian.AppendUint16(artist, u)
+	}
+	if got := frames["TPE1"]; !bytes.Equal(got, artist) {
+		t.Errorf("TPE1=%x, want %x", got, artist)
+	}
+	if got := string(frames["COMM"]); got != "\x00eng\x00voice" {
+		t.Errorf("COMM=%q", got)
+	}
+	// 空字段不写入
+	if tag = (&ID3Tags{}).marshal(); len(tag) != 10 {
+		t.Errorf("empty tag: %x", tag)
+	}
+}
+
+func TestMP3CfgValidate(t *testing.T) {
+	if err := buildMP3Cfg().Validate(); err != nil {
+		t.Errorf("default config: %v", err)
+	}
+	var cfg = buildMP3Cfg(func(mc *MP3Cfg) {
+		mc.Channels, mc.Bitrate, mc.VBR, mc.Quality, mc.OutSampleRate = 3, 500, 10, -2, 96000
+	})
+	var err = cfg.Validate()
+	for _, field := range []string{"Channels", "Bitrate", "VBR", "Quality", "OutSampleRate"} {
+		if err == nil || !bytes.Contains([]byte(err.Error()), []byte("invalid "+field)) {
+			t.Errorf("want error of %s, got %v", field, err)
+		}
+	}
+}
+
+func TestDecodeToMP3(t *testing.T) {
+	data, err := os.ReadFile("../cmd/testdata/hao.amr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = DecodeToMP3(bytes.NewReader(data), &buf, func(mc *MP3Cfg) {
+		mc.ID3 = &ID3Tags{Title: "hao"}
+		mc.Decode = []DecodeOpt{func(dc *DecodeCfg) { dc.Channels = 2 }}
+	})
+	if !MP3Supported {
+		if !errors.Is(err, ErrMP3Unsupported) || buf.Len() > 0 {
+			t.Errorf("without lame: err=%v, output %d bytes", err, buf.Len())
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tag = (&ID3Tags{Title: "hao"}).marshal()
+	if !bytes.HasPrefix(buf.Bytes(), tag) || buf.Len() <= len(tag) {
+		t.Errorf("unexpected output: %d bytes", buf.Len())
+	}
+}
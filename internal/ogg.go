@@ -0,0 +1,349 @@
+package internal
+
+/*
+#include "SKP_Silk_SDK_API.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// Ogg 封装格式说明
+// https://datatracker.ietf.org/doc/html/rfc3533
+//
+// SILK 没有标准的 Ogg 映射, 这里参考 Opus(RFC 7845) 定义:
+// 第一页(BOS)只包含一个 ID header 包, 之后每个 Ogg 包就是一个 silk 数据包(不含长度前缀),
+// granule position 是该页最后一个完整数据包结束时的采样数(按 API 采样率计算).
+//
+// ID header 格式(多字节整数均为小端序):
+//
+//	0   8  magic "SilkHead"
+//	8   1  版本号, 目前是 1
+//	9   1  声道数, 目前是 1
+//	10  4  API 采样率(Hz)
+//	14  2  每个数据包的长度(ms)
+//	16  1  编码器版本号字符串的长度 n
+//	17  n  编码器版本号, 即 SKP_Silk_SDK_get_version 的返回值
+const (
+	OggMagic      = "OggS"
+	OggHeadMagic  = "SilkHead"
+	oggHeadVer    = 1
+	oggHeadLen    = 17
+	oggFlagCont   = 0x01 // 该页第一个包是上一页的延续
+	oggFlagBOS    = 0x02 // 流的第一页
+	oggFlagEOS    = 0x04 // 流的最后一页
+	oggMaxSegs    = 255
+	oggPagePacket = 50 // 每页最多放多少个数据包, 20ms 的包相当于 1s 一页
+)
+
+// OggHead is the ID header of a silk Ogg stream.
+// Ogg 封装中的 ID header
+type OggHead struct {
+	SampleRate   int
+	PacketSizeMs int
+	Version      string
+}
+
+// Version returns the version of the silk SDK.
+// 返回 C 语言版本 SDK 的版本号
+func Version() string {
+	// const char *SKP_Silk_SDK_get_version();
+	return C.GoString(C.SKP_Silk_SDK_get_version())
+}
+
+func (h *OggHead) marshal() []byte {
+	var buf = make([]byte, oggHeadLen, oggHeadLen+len(h.Version))
+	copy(buf, OggHeadMagic)
+	buf[8] = oggHeadVer
+	buf[9] = 1
+	binary.LittleEndian.PutUint32(buf[10:], uint32(h.SampleRate))
+	binary.LittleEndian.PutUint16(buf[14:], uint16(h.PacketSizeMs))
+	buf[16] = byte(len(h.Version))
+	return append(buf, h.Version...)
+}
+
+func (h *OggHead) unmarshal(buf []byte) error {
+	if len(buf) < oggHeadLen || string(buf[:8]) != OggHeadMagic {
+		return fmt.Errorf("invalid ogg stream, not a silk ID header")
+	}
+	if buf[8] != oggHeadVer {
+		return fmt.Errorf("unsupported silk ogg version: %d", buf[8])
+	}
+	var n = int(buf[16])
+	if len(buf) < oggHeadLen+n {
+		return fmt.Errorf("invalid silk ID header")
+	}
+	h.SampleRate = int(binary.LittleEndian.Uint32(buf[10:]))
+	h.PacketSizeMs = int(binary.LittleEndian.Uint16(buf[14:]))
+	h.Version = string(buf[oggHeadLen : oggHeadLen+n])
+	return nil
+}
+
+// oggCRCTable is the table of the CRC-32 used by Ogg:
+// polynomial 0x04c11db7, no reflection, initial value and final xor are 0.
+var oggCRCTable = func() (table [256]uint32) {
+	for i := range table {
+		var r = uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return
+}()
+
+func oggCRC(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// -------------------- Ogg writer --------------------
+
+// oggWriter writes silk packets as an Ogg stream.
+// 将 silk 数据包写为 Ogg 流
+type oggWriter struct {
+	out              io.Writer
+	serial           uint32
+	pageSeq          uint32
+	granule          int64
+	samplesPerPacket int64
+	segments         []byte // 当前页的 lacing values
+	data             bytes.Buffer
+	packets          int
+}
+
+func newOggWriter(out io.Writer, cfg *EncodeCfg) (*oggWriter, error) {
+	var w = &oggWriter{
+		out:              out,
+		serial:           rand.Uint32(),
+		samplesPerPacket: int64(cfg.PacketSizeMs * cfg.SampleRate / 1000),
+	}
+	var head = &OggHead{
+		SampleRate:   cfg.SampleRate,
+		PacketSizeMs: cfg.PacketSizeMs,
+		Version:      Version(),
+	}
+	w.addPacket(head.marshal())
+	if err := w.flush(oggFlagBOS, 0); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *oggWriter) WritePacket(payload []byte) error {
+	// 一个包需要 len/255+1 个 lacing value, 放不下就先输出当前页
+	if len(w.segments)+len(payload)/255+1 > oggMaxSegs || w.packets >= oggPagePacket {
+		if err := w.flush(0, w.granule); err != nil {
+			return err
+		}
+	}
+	w.addPacket(payload)
+	w.granule += w.samplesPerPacket
+	return nil
+}
+
+func (w *oggWriter) Close() error {
+	return w.flush(oggFlagEOS, w.granule)
+}
+
+func (w *oggWriter) addPacket(payload []byte) {
+	var n = len(payload)
+	for n >= 255 {
+		w.segments = append(w.segments, 255)
+		n -= 255
+	}
+	w.segments = append(w.segments, byte(n))
+	w.data.Write(payload)
+	w.packets++
+}
+
+func (w *oggWriter) flush(flag byte, granule int64) error {
+	if len(w.segments) == 0 && flag&oggFlagEOS == 0 {
+		return nil
+	}
+	var page = make([]byte, 27, 27+len(w.segments)+w.data.Len())
+	copy(page, OggMagic)
+	page[5] = flag
+	binary.LittleEndian.PutUint64(page[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:], w.serial)
+	binary.LittleEndian.PutUint32(page[18:], w.pageSeq)
+	page[26] = byte(len(w.segments))
+	page = append(page, w.segments...)
+	page = append(page, w.data.Bytes()...)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(0, page))
+	log("ogg page=%d, flag=%x, granule=%d, segments=%d, size=%d", w.pageSeq, flag, granule, len(w.segments), len(page))
+
+	w.pageSeq++
+	w.segments = w.segments[:0]
+	w.data.Reset()
+	w.packets = 0
+	if _, err := w.out.Write(page); err != nil {
+		return fmt.Errorf("failed to write ogg page: %w", err)
+	}
+	return nil
+}
+
+// -------------------- Ogg reader --------------------
+
+// oggPage is a parsed Ogg page.
+type oggPage struct {
+	Flag     byte
+	Granule  int64
+	Serial   uint32
+	Sequence uint32
+	Segments []byte
+	Data     []byte
+}
+
+// size returns the byte size of the page.
+func (p *oggPage) size() int64 {
+	return int64(27 + len(p.Segments) + len(p.Data))
+}
+
+// readOggPage reads one Ogg page, returns io.EOF if there is no more page.
+func readOggPage(reader io.Reader) (*oggPage, error) {
+	var header = make([]byte, 27)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read ogg page header: %w", err)
+	}
+	if string(header[:4]) != OggMagic || header[4] != 0 {
+		return nil, fmt.Errorf("invalid ogg page, capture pattern=%q, version=%d", header[:4], header[4])
+	}
+	var segments = make([]byte, header[26])
+	if _, err := io.ReadFull(reader, segments); err != nil {
+		return nil, fmt.Errorf("failed to read ogg segment table: %w", err)
+	}
+	var size int
+	for _, s := range segments {
+		size += int(s)
+	}
+	var data = make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("failed to read ogg page data: %w", err)
+	}
+
+	var crc = binary.LittleEndian.Uint32(header[22:])
+	binary.LittleEndian.PutUint32(header[22:], 0)
+	if got := oggCRC(oggCRC(oggCRC(0, header), segments), data); got != crc {
+		return nil, fmt.Errorf("ogg page checksum mismatch, got=%x, expected=%x", got, crc)
+	}
+	return &oggPage{
+		Flag:     header[5],
+		Granule:  int64(binary.LittleEndian.Uint64(header[6:])),
+		Serial:   binary.LittleEndian.Uint32(header[14:]),
+		Sequence: binary.LittleEndian.Uint32(header[18:]),
+		Segments: segments,
+		Data:     data,
+	}, nil
+}
+
+// oggReader reads silk packets from an Ogg stream.
+// 从 Ogg 流中读取 silk 数据包
+type oggReader struct {
+	reader   io.Reader
+	Head     OggHead
+	serial   uint32
+	packets  [][]byte // 当前页中已经完整的数据包
+	starts   []int64  // packets 中每个包开始的那一页的偏移
+	partial  []byte   // 跨页的数据包
+	begin    int64    // partial 开始的那一页的偏移
+	start    int64    // 上一次返回的包开始的那一页的偏移, 用于建立索引
+	pos      int64    // 下一页的偏移
+	dropCont bool     // 丢弃第一页开头延续自上一页的数据, 跳转时使用
+	eos      bool
+}
+
+func newOggReader(reader io.Reader) (*oggReader, error) {
+	var r = &oggReader{reader: reader}
+	// 找到 silk 流的第一页, 其他逻辑流忽略
+	for {
+		page, err := readOggPage(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("invalid ogg stream, silk ID header not found")
+			}
+			return nil, err
+		}
+		r.pos += page.size()
+		if page.Flag&oggFlagBOS == 0 {
+			continue
+		}
+		if err = r.Head.unmarshal(page.Data); err != nil {
+			log("skip ogg stream serial=%x: %+v", page.Serial, err)
+			continue
+		}
+		r.serial = page.Serial
+		log("ogg stream serial=%x, head=%+v", r.serial, r.Head)
+		return r, nil
+	}
+}
+
+// seek returns a reader of the same stream which reads from the page at offset pos,
+// the reader should be positioned at that page.
+// 返回同一个流从 pos 偏移处的页开始读取的读取器, reader 需要已经定位到该页
+func (r *oggReader) seek(reader io.Reader, pos int64) *oggReader {
+	return &oggReader{reader: reader, Head: r.Head, serial: r.serial, pos: pos, dropCont: true}
+}
+
+func (r *oggReader) ReadPacket() ([]byte, error) {
+	for len(r.packets) == 0 {
+		if r.eos {
+			return nil, io.EOF
+		}
+		page, err := readOggPage(r.reader)
+		if err != nil {
+			return nil, err
+		}
+		var pos = r.pos
+		r.pos += page.size()
+		if page.Serial != r.serial {
+			continue
+		}
+		if page.Flag&oggFlagCont == 0 {
+			r.partial = nil
+			r.dropCont = false
+		}
+		r.eos = page.Flag&oggFlagEOS != 0
+		var offset int
+		for _, s := range page.Segments {
+			var segment = page.Data[offset : offset+int(s)]
+			offset += int(s)
+			if r.dropCont { // 上一个包的剩余部分
+				r.dropCont = s == 255
+				continue
+			}
+			if len(r.partial) == 0 {
+				r.begin = pos
+			}
+			r.partial = append(r.partial, segment...)
+			if s < 255 { // 小于 255 表示包结束
+				var packet = r.partial
+				if packet == nil {
+					packet = []byte{}
+				}
+				r.packets = append(r.packets, packet)
+				r.starts = append(r.starts, r.begin)
+				r.partial = nil
+			}
+		}
+	}
+	var packet = r.packets[0]
+	r.start = r.starts[0]
+	r.packets, r.starts = r.packets[1:], r.starts[1:]
+	return packet, nil
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestOgg(t *testing.T) {
+	pcm, err := os.ReadFile("../cmd/testdata/hao.decode.pcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	silk, err := Encode(bytes.NewReader(pcm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ogg, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) { ec.Ogg = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(ogg, []byte(OggMagic)) {
+		t.Fatalf("ogg stream should starts with %q", OggMagic)
+	}
+
+	want, err := Decode(bytes.NewReader(silk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(bytes.NewReader(ogg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("decoded ogg stream differs from silk file, len=%d, want=%d", len(got), len(want))
+	}
+
+	r, err := newOggReader(bytes.NewReader(ogg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Head.SampleRate != defaultSampleRate || r.Head.PacketSizeMs != 20 || r.Head.Version != Version() {
+		t.Errorf("unexpected ID header: %+v", r.Head)
+	}
+
+	ogg[len(ogg)-1] ^= 0xff // 损坏最后一页
+	if _, err = Decode(bytes.NewReader(ogg)); err == nil {
+		t.Errorf("expected checksum error")
+	}
+}
@@ -0,0 +1,319 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// pcap / pcapng 格式说明
+// https://wiki.wireshark.org/Development/LibpcapFileFormat
+// https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-01.html
+
+const (
+	pcapMagicMicro    = 0xa1b2c3d4 // 经典 pcap, 时间戳精度为微秒
+	pcapMagicNano     = 0xa1b23c4d // 经典 pcap, 时间戳精度为纳秒
+	pcapngBlockSHB    = 0x0a0d0d0a // Section Header Block
+	pcapngBlockIDB    = 0x00000001 // Interface Description Block
+	pcapngBlockOPB    = 0x00000002 // Packet Block(已废弃)
+	pcapngBlockSPB    = 0x00000003 // Simple Packet Block
+	pcapngBlockEPB    = 0x00000006 // Enhanced Packet Block
+	pcapngByteOrder   = 0x1a2b3c4d
+	pcapMaxPacketSize = 1 << 18 // 单个包最大 256K, 超过认为文件损坏
+
+	linkTypeNull   = 0   // BSD loopback
+	linkTypeEther  = 1   // Ethernet
+	linkTypeRaw    = 101 // Raw IP
+	linkTypeRawOld = 12  // Raw IP(OpenBSD)
+	linkTypeLoop   = 108 // OpenBSD loopback
+	linkTypeIPv4   = 228
+	linkTypeIPv6   = 229
+	linkTypeSLL    = 113 // Linux cooked capture
+	linkTypeSLL2   = 276 // Linux cooked capture v2
+)
+
+// udpPayload is the payload of an UDP datagram read from a capture file.
+// 从抓包文件中读取的 UDP 数据
+type udpPayload struct {
+	SrcPort, DstPort uint16
+	Data             []byte
+}
+
+// readPcapUDP reads all UDP datagrams in a pcap or pcapng file.
+// 读取 pcap/pcapng 文件中所有的 UDP 数据包
+func readPcapUDP(src io.Reader) ([]udpPayload, error) {
+	var reader = bufio.NewReader(src)
+	magic, err := reader.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pcap magic number: %w", err)
+	}
+	var frames []linkFrame
+	switch {
+	case binary.LittleEndian.Uint32(magic) == pcapngBlockSHB:
+		frames, err = readPcapng(reader)
+	case binary.LittleEndian.Uint32(magic) == pcapMagicMicro, binary.LittleEndian.Uint32(magic) == pcapMagicNano,
+		binary.BigEndian.Uint32(magic) == pcapMagicMicro, binary.BigEndian.Uint32(magic) == pcapMagicNano:
+		frames, err = readPcap(reader)
+	default:
+		return nil, fmt.Errorf("invalid pcap file, magic number=%x", magic)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result []udpPayload
+	for i, frame := range frames {
+		udp, ok := parseLinkFrame(frame.LinkType, frame.Data)
+		if !ok {
+			log("capture frame=%d, not an udp packet, skip", i+1)
+			continue
+		}
+		result = append(result, udp)
+	}
+	log("read %d frames from pcap file, %d udp packets", len(frames), len(result))
+	return result, nil
+}
+
+// linkFrame is a captured frame with its link type.
+type linkFrame struct {
+	LinkType uint32
+	Data     []byte
+}
+
+func readPcap(reader io.Reader) ([]linkFrame, error) {
+	var header = make([]byte, 24)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("failed to read pcap header: %w", err)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if m := binary.BigEndian.Uint32(header); m == pcapMagicMicro || m == pcapMagicNano {
+		order = binary.BigEndian
+	}
+	var linkType = order.Uint32(header[20:])
+	var frames []linkFrame
+	var record = make([]byte, 16)
+	for {
+		_, err := io.ReadFull(reader, record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pcap record header: %w", err)
+		}
+		var inclLen = order.Uint32(record[8:])
+		if inclLen > pcapMaxPacketSize {
+			return nil, fmt.Errorf("invalid pcap record length: %d", inclLen)
+		}
+		var data = make([]byte, inclLen)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			return nil, fmt.Errorf("failed to read pcap record: %w", err)
+		}
+		frames = append(frames, linkFrame{LinkType: linkType, Data: data})
+	}
+	return frames, nil
+}
+
+func readPcapng(reader io.Reader) ([]linkFrame, error) {
+	var (
+		order      binary.ByteOrder = binary.LittleEndian
+		interfaces []uint32         // link type of each interface, in current section
+		frames     []linkFrame
+		head       = make([]byte, 8)
+	)
+	for {
+		_, err := io.ReadFull(reader, head)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pcapng block header: %w", err)
+		}
+		var blockType = order.Uint32(head)
+		if blockType == pcapngBlockSHB {
+			// 每个 Section 都可能有不同的字节序, 需要根据 Byte-Order Magic 判断
+			var bom = make([]byte, 4)
+			if _, err = io.ReadFull(reader, bom); err != nil {
+				return nil, fmt.Errorf("failed to read pcapng byte-order magic: %w", err)
+			}
+			if binary.BigEndian.Uint32(bom) == pcapngByteOrder {
+				order = binary.BigEndian
+			} else if binary.LittleEndian.Uint32(bom) == pcapngByteOrder {
+				order = binary.LittleEndian
+			} else {
+				return nil, fmt.Errorf("invalid pcapng byte-order magic: %x", bom)
+			}
+			interfaces = interfaces[:0]
+			if err = skipBlock(reader, order.Uint32(head[4:]), 12); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var totalLen = order.Uint32(head[4:])
+		if totalLen < 12 || totalLen%4 != 0 || totalLen > pcapMaxPacketSize {
+			return nil, fmt.Errorf("invalid pcapng block length: %d", totalLen)
+		}
+		var body = make([]byte, totalLen-8)
+		if _, err = io.ReadFull(reader, body); err != nil {
+			return nil, fmt.Errorf("failed to read pcapng block: %w", err)
+		}
+		body = body[:len(body)-4] // 去掉末尾重复的 block 长度
+		switch blockType {
+		case pcapngBlockIDB:
+			if len(body) < 8 {
+				return nil, fmt.Errorf("invalid pcapng interface block")
+			}
+			interfaces = append(interfaces, uint32(order.Uint16(body)))
+		case pcapngBlockEPB:
+			if len(body) < 20 {
+				return nil, fmt.Errorf("invalid pcapng enhanced packet block")
+			}
+			var iface, capLen = order.Uint32(body), order.Uint32(body[12:])
+			if int(iface) >= len(interfaces) || int(capLen) > len(body)-20 {
+				return nil, fmt.Errorf("invalid pcapng enhanced packet block")
+			}
+			frames = append(frames, linkFrame{LinkType: interfaces[iface], Data: body[20 : 20+capLen]})
+		case pcapngBlockOPB:
+			if len(body) < 20 {
+				return nil, fmt.Errorf("invalid pcapng packet block")
+			}
+			var iface, capLen = order.Uint16(body), order.Uint32(body[12:])
+			if int(iface) >= len(interfaces) || int(capLen) > len(body)-20 {
+				return nil, fmt.Errorf("invalid pcapng packet block")
+			}
+			frames = append(frames, linkFrame{LinkType: interfaces[iface], Data: body[20 : 20+capLen]})
+		case pcapngBlockSPB:
+			if len(body) < 4 || len(interfaces) == 0 {
+				return nil, fmt.Errorf("invalid pcapng simple packet block")
+			}
+			// 简单包只有原始长度, 实际长度受 snaplen 限制, 这里直接取剩余部分
+			var origLen = int(order.Uint32(body))
+			var data = body[4:]
+			if origLen < len(data) {
+				data = data[:origLen]
+			}
+			frames = append(frames, linkFrame{LinkType: interfaces[0], Data: data})
+		default:
+			// 其他类型的 block 不关心
+		}
+	}
+	return frames, nil
+}
+
+// skipBlock skips the rest of a block whose total length is totalLen and read bytes is read.
+func skipBlock(reader io.Reader, totalLen uint32, read int) error {
+	if totalLen < uint32(read)+4 || totalLen%4 != 0 || totalLen > pcapMaxPacketSize {
+		return fmt.Errorf("invalid pcapng block length: %d", totalLen)
+	}
+	_, err := io.CopyN(io.Discard, reader, int64(totalLen)-int64(read))
+	if err != nil {
+		return fmt.Errorf("failed to read pcapng block: %w", err)
+	}
+	return nil
+}
+
+// parseLinkFrame extracts UDP payload from a link layer frame.
+// 从链路层数据中解析出 UDP 数据
+func parseLinkFrame(linkType uint32, data []byte) (udpPayload, bool) {
+	switch linkType {
+	case linkTypeEther:
+		if len(data) < 14 {
+			return udpPayload{}, false
+		}
+		var etherType, offset = binary.BigEndian.Uint16(data[12:]), 14
+		for etherType == 0x8100 || etherType == 0x88a8 { // VLAN tag
+			if len(data) < offset+4 {
+				return udpPayload{}, false
+			}
+			etherType = binary.BigEndian.Uint16(data[offset+2:])
+			offset += 4
+		}
+		return parseIP(etherType, data[offset:])
+	case linkTypeNull, linkTypeLoop:
+		// 4 字节的协议族, 字节序和抓包机器一致, 直接看 IP 版本号
+		if len(data) < 4 {
+			return udpPayload{}, false
+		}
+		return parseIP(0, data[4:])
+	case linkTypeRaw, linkTypeRawOld, linkTypeIPv4, linkTypeIPv6:
+		return parseIP(0, data)
+	case linkTypeSLL:
+		if len(data) < 16 {
+			return udpPayload{}, false
+		}
+		return parseIP(binary.BigEndian.Uint16(data[14:]), data[16:])
+	case linkTypeSLL2:
+		if len(data) < 20 {
+			return udpPayload{}, false
+		}
+		return parseIP(binary.BigEndian.Uint16(data), data[20:])
+	}
+	return udpPayload{}, false
+}
+
+// parseIP parses IPv4/IPv6 packet, etherType 0 means detect by version field.
+func parseIP(etherType uint16, data []byte) (udpPayload, bool) {
+	if len(data) < 1 {
+		return udpPayload{}, false
+	}
+	var version = data[0] >> 4
+	switch {
+	case etherType == 0x0800 || (etherType == 0 && version == 4):
+		if len(data) < 20 || version != 4 {
+			return udpPayload{}, false
+		}
+		var ihl = int(data[0]&0x0f) * 4
+		var totalLen = int(binary.BigEndian.Uint16(data[2:]))
+		var fragment = binary.BigEndian.Uint16(data[6:])
+		if ihl < 20 || totalLen < ihl || totalLen > len(data) {
+			return udpPayload{}, false
+		}
+		if fragment&0x3fff != 0 { // 分片的包(MF 标记或偏移非 0)不处理
+			return udpPayload{}, false
+		}
+		if data[9] != 17 { // UDP
+			return udpPayload{}, false
+		}
+		return parseUDP(data[ihl:totalLen])
+	case etherType == 0x86dd || (etherType == 0 && version == 6):
+		if len(data) < 40 || version != 6 {
+			return udpPayload{}, false
+		}
+		var next, payload = data[6], data[40:]
+		var payloadLen = int(binary.BigEndian.Uint16(data[4:]))
+		if payloadLen < len(payload) {
+			payload = payload[:payloadLen]
+		}
+		// 跳过扩展头: Hop-by-Hop, Routing, Destination Options
+		for next == 0 || next == 43 || next == 60 {
+			if len(payload) < 8 {
+				return udpPayload{}, false
+			}
+			var extLen = (int(payload[1]) + 1) * 8
+			if len(payload) < extLen {
+				return udpPayload{}, false
+			}
+			next, payload = payload[0], payload[extLen:]
+		}
+		if next != 17 {
+			return udpPayload{}, false
+		}
+		return parseUDP(payload)
+	}
+	return udpPayload{}, false
+}
+
+func parseUDP(data []byte) (udpPayload, bool) {
+	if len(data) < 8 {
+		return udpPayload{}, false
+	}
+	var length = int(binary.BigEndian.Uint16(data[4:]))
+	if length < 8 || length > len(data) {
+		return udpPayload{}, false
+	}
+	return udpPayload{
+		SrcPort: binary.BigEndian.Uint16(data),
+		DstPort: binary.BigEndian.Uint16(data[2:]),
+		Data:    data[8:length],
+	}, true
+}
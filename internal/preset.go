@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// 编码预设: 一组编码参数, 以及目标应用对输出格式的要求.
+// 预设之后的选项可以覆盖预设的参数, 但编码前会检查是否仍然满足目标应用的要求.
+
+// preset is a named bundle of encode options with the constraints of its target.
+type preset struct {
+	apply func(*EncodeCfg)
+	check func(*EncodeCfg) []error
+}
+
+var presets = map[string]preset{
+	// 微信/QQ 语音: STX 前缀, 无 footer, 24kHz 内部采样率, 20ms 数据包
+	"wechat": {
+		apply: func(ec *EncodeCfg) {
+			ec.MaxInternalSampleRate = 24000
+			ec.PacketSizeMs = 20
+			ec.BitRate = 24000
+			ec.ComplexityMode = 2
+			ec.Stx = true
+		},
+		check: checkTencent,
+	},
+	"qq": {
+		apply: func(ec *EncodeCfg) {
+			ec.MaxInternalSampleRate = 24000
+			ec.PacketSizeMs = 20
+			ec.BitRate = 25000
+			ec.ComplexityMode = 2
+			ec.Stx = true
+		},
+		check: checkTencent,
+	},
+	// 窄带网络电话: 8kHz 内部采样率, 低码率, 开启 FEC 和 DTX
+	"voip-narrowband": {
+		apply: func(ec *EncodeCfg) {
+			ec.MaxInternalSampleRate = 8000
+			ec.PacketSizeMs = 20
+			ec.BitRate = 12000
+			ec.PacketLossPct = 5
+			ec.UseInBandFEC = true
+			ec.UseDTX = true
+		},
+		check: func(ec *EncodeCfg) (errs []error) {
+			if ec.MaxInternalSampleRate > 8000 {
+				errs = append(errs, fmt.Errorf("max internal sample rate must not exceed 8000, got %d", ec.MaxInternalSampleRate))
+			}
+			return errs
+		},
+	},
+	// 高音质: 24kHz 内部采样率, 高码率, 最高复杂度
+	"high-quality": {
+		apply: func(ec *EncodeCfg) {
+			ec.MaxInternalSampleRate = 24000
+			ec.BitRate = 40000
+			ec.ComplexityMode = 2
+			ec.UseDTX = false
+		},
+		check: func(ec *EncodeCfg) (errs []error) {
+			if ec.UseDTX {
+				errs = append(errs, errors.New("DTX must be disabled"))
+			}
+			return errs
+		},
+	},
+}
+
+// checkTencent checks the file layout accepted by WeChat/QQ.
+func checkTencent(ec *EncodeCfg) (errs []error) {
+	if !ec.Stx {
+		errs = append(errs, errors.New("STX flag is required"))
+	}
+	if ec.Ogg || ec.NoHeader || ec.PacketLengths != nil {
+		errs = append(errs, errors.New("output must be silk v3 file"))
+	}
+	if (ec.LengthSize != 0 && ec.LengthSize != 2) || ec.LengthBigEndian {
+		errs = append(errs, errors.New("block length prefix must be 2 bytes little endian"))
+	}
+	if ec.PacketSizeMs != 20 {
+		errs = append(errs, fmt.Errorf("packet size must be 20ms, got %dms", ec.PacketSizeMs))
+	}
+	return errs
+}
+
+// Preset returns the encode option of the named preset, the option records the preset,
+// and Encode checks its constraints after all options are applied.
+// 返回指定名称的预设选项, 编码前会检查最终的参数是否满足预设的要求
+func Preset(name string) (EncodeOpt, bool) {
+	p, ok := presets[name]
+	if !ok {
+		return nil, false
+	}
+	return func(ec *EncodeCfg) {
+		p.apply(ec)
+		ec.Preset = name
+	}, true
+}
+
+// PresetNames returns the names of all presets.
+// 返回所有预设的名称
+func PresetNames() []string {
+	var names = make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkPreset checks the constraints of the preset applied.
+func (cfg *EncodeCfg) checkPreset() error {
+	if cfg.Preset == "" {
+		return nil
+	}
+	p, ok := presets[cfg.Preset]
+	if !ok {
+		return fmt.Errorf("unknown preset %q", cfg.Preset)
+	}
+	if errs := p.check(cfg); len(errs) > 0 {
+		return fmt.Errorf("options conflict with preset %q: %w", cfg.Preset, errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestPreset(t *testing.T) {
+	var pcm = sinePCM(defaultSampleRate, 1, 0.3)
+	for _, tt := range []struct {
+		name   string
+		stx    bool
+		footer bool
+		fsKHz  int // 内部采样率上限
+	}{
+		{"wechat", true, false, 24},
+		{"qq", true, false, 24},
+		{"voip-narrowband", false, true, 8},
+		{"high-quality", false, true, 24},
+	} {
+		opt, ok := Preset(tt.name)
+		if !ok {
+			t.Fatalf("preset %s not found", tt.name)
+		}
+		data, err := Encode(bytes.NewReader(pcm), opt)
+		if err != nil {
+			t.Fatal(tt.name, err)
+		}
+		var header = []byte(Header)
+		if tt.stx {
+			header = append([]byte{STX}, header...)
+		}
+		if !bytes.HasPrefix(data, header) {
+			t.Errorf("%s: unexpected header % x", tt.name, data[:len(header)])
+			continue
+		}
+		// 按 [长度][内容] 遍历所有数据块
+		var (
+			rest   = data[len(header):]
+			footer bool
+			fsKHz  int
+		)
+		for len(rest) >= 2 {
+			var size = int(int16(binary.LittleEndian.Uint16(rest)))
+			rest = rest[2:]
+			if size < 0 {
+				footer = true
+				break
+			}
+			if toc, ok := readTOC(rest[:size]); ok && int(toc.fs_kHz) > fsKHz {
+				fsKHz = int(toc.fs_kHz)
+			}
+			rest = rest[size:]
+		}
+		if len(rest) != 0 || footer != tt.footer {
+			t.Errorf("%s: footer=%v, %d bytes left, want footer=%v", tt.name, footer, len(rest), tt.footer)
+		}
+		if fsKHz == 0 || fsKHz > tt.fsKHz {
+			t.Errorf("%s: internal sample rate %dkHz, want <= %dkHz", tt.name, fsKHz, tt.fsKHz)
+		}
+	}
+
+	// 之后的选项与预设冲突
+	opt, _ := Preset("wechat")
+	_, err := Encode(bytes.NewReader(pcm), opt, func(ec *EncodeCfg) { ec.Stx = false; ec.PacketSizeMs = 40 })
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	// 不冲突的选项可以覆盖预设
+	if _, err = Encode(bytes.NewReader(pcm), opt, func(ec *EncodeCfg) { ec.BitRate = 30000 }); err != nil {
+		t.Error(err)
+	}
+	if _, ok := Preset("unknown"); ok {
+		t.Error("unknown preset should not be found")
+	}
+	var names = PresetNames()
+	if len(names) != 4 || names[0] != "high-quality" {
+		t.Errorf("unexpected preset names: %v", names)
+	}
+}
@@ -0,0 +1,201 @@
+package internal
+
+/*
+#include "SKP_Silk_SDK_API.h"
+#include "SKP_Silk_define.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// 码率控制: silk 是可变码率的, 目标码率只是一个参考, 实际输出大小无法预测.
+// TargetSize 模式根据剩余预算逐帧调整目标码率, 使输出接近(不超过)指定大小;
+// CBR 模式限制数据包大小, 不足的部分补零: 解码器按码流中记录的帧数解码, 查找冗余数据(LBRR)时也只解析码流,
+// 数据包末尾多余的 0 不影响解码结果(见 TestCBRPaddingDecode).
+
+// EncodeStats is the statistics of an encoding.
+// 编码统计信息
+type EncodeStats struct {
+	Frames    int           // frames(20ms) encoded, including the padding of the last packet
+	Samples   int64         // samples of the input, excluding the padding
+	Packets   int           // packets output
+	Duration  time.Duration // duration encoded
+	Payload   int64         // total bytes of packets
+	Padding   int64         // bytes padded in CBR mode
+	Oversize  int           // packets larger than the CBR packet size
+	MinPacket int           // size of the smallest packet
+	MaxPacket int           // size of the largest packet
+	Bytes     int64         // size of the output, including header and footer
+	BitRate   int           // average bitrate of the output
+}
+
+// finish computes the average bitrate of the output.
+func (s *EncodeStats) finish(bytes int64) {
+	s.Bytes = bytes
+	if s.Duration > 0 {
+		s.BitRate = int(float64(bytes*8) / s.Duration.Seconds())
+	}
+}
+
+// rateControl adjusts the target bitrate per frame for TargetSize, CBR and two-pass mode, and collects statistics.
+// 逐帧调整目标码率, 并收集统计信息
+type rateControl struct {
+	control   *C.SKP_SILK_SDK_EncControlStruct
+	cbr       int       // CBR 模式的数据包大小, 0 表示不启用
+	budget    int64     // TargetSize 模式/两遍编码: 所有数据包的总预算, 0 表示不启用
+	frames    int       // TargetSize 模式/两遍编码: 总帧数
+	weights   []float64 // 两遍编码: 每帧的码率权重
+	rest      []float64 // 两遍编码: rest[i] 是第 i 帧及之后所有帧的权重之和
+	perPacket int       // 每个数据包的帧数
+	pending   int       // 还没有输出数据包的帧数(PacketSizeMs > 20 时)
+	target    float64   // 还没有输出数据包的各帧目标比特数之和
+	scale     float64   // 实际码率与目标码率之比的估计值
+	stats     EncodeStats
+}
+
+const (
+	rateSmooth   = 0.1  // scale 的平滑系数
+	cbrMargin    = 0.7  // CBR 模式的目标码率留一些余量, 减少超出的数据包
+	targetMargin = 0.98 // TargetSize 模式留一些预算, 避免最后几帧超出
+)
+
+func newRateControl(cfg *EncodeCfg, control *C.SKP_SILK_SDK_EncControlStruct) *rateControl {
+	var rc = &rateControl{control: control, cbr: cfg.CBRPacketSize, perPacket: cfg.PacketSizeMs / FRAME_LENGTH_MS, scale: 1}
+	if rc.cbr > 0 {
+		rc.setBitRate(float64(rc.cbr*8*1000/cfg.PacketSizeMs) * cbrMargin)
+	}
+	return rc
+}
+
+// minCBRPacketSize returns the smallest CBR packet size for packetSizeMs, packets are mostly oversize below it.
+func minCBRPacketSize(packetSizeMs int) int {
+	return int(math.Ceil(C.MIN_TARGET_RATE_BPS * float64(packetSizeMs) / 8000 / cbrMargin))
+}
+
+// setTarget enables TargetSize mode, overhead is the bytes of the output other than the packets.
+func (rc *rateControl) setTarget(size, overhead int64, frames int) error {
+	var minSize = overhead + int64(frames)*C.MIN_TARGET_RATE_BPS*FRAME_LENGTH_MS/1000/8
+	if size < minSize {
+		return fmt.Errorf("target size %d bytes is too small for %v, at least %d bytes",
+			size, time.Duration(frames)*FRAME_LENGTH_MS*time.Millisecond, minSize)
+	}
+	rc.budget, rc.frames = int64(float64(size-overhead)*targetMargin), frames
+	rc.adjust()
+	return nil
+}
+
+// frame records a frame(with samples of input) encoded, complete is true when a packet is output:
+// every PacketSizeMs, even when the packet is empty(DTX), or whenever the encoder returns a packet,
+// since the encoder restarts the packet when its internal sample rate changes. The packet is padded in CBR mode.
+// It adjusts the bitrate for the next frame.
+// 记录编码的一帧, 每 PacketSizeMs 输出一个数据包(DTX 时数据包为空), CBR 模式下数据包会补零;
+// 编码器内部采样率变化时会重新开始一个数据包, 所以编码器返回数据时总是作为一个完整的包
+func (rc *rateControl) frame(packet []byte, samples int) (out []byte, complete bool) {
+	var s = &rc.stats
+	s.Frames++
+	s.Samples += int64(samples)
+	s.Duration += FRAME_LENGTH_MS * time.Millisecond
+	rc.pending++
+	// 这一帧编码时使用的目标码率
+	rc.target += float64(rc.control.bitRate) * FRAME_LENGTH_MS / 1000
+	if rc.pending < rc.perPacket && len(packet) == 0 { // 多帧数据包还未完成
+		rc.adjust()
+		return nil, false
+	}
+	if len(packet) > 0 && rc.target > 0 {
+		// 根据数据包的实际大小与包内各帧目标之和更新 scale, 而不只是最后一帧的目标码率
+		rc.scale += (float64(len(packet)*8)/rc.target - rc.scale) * rateSmooth
+	}
+	rc.pending, rc.target = 0, 0
+
+	if rc.cbr > 0 && len(packet) > 0 { // DTX 的空数据包不补零
+		if len(packet) > rc.cbr {
+			s.Oversize++
+			rc.scale *= float64(len(packet)) / float64(rc.cbr) // 超出时立即降低码率
+		} else {
+			s.Padding += int64(rc.cbr - len(packet))
+			packet = append(packet, make([]byte, rc.cbr-len(packet))...)
+		}
+	}
+	s.Packets++
+	s.Payload += int64(len(packet))
+	if s.Packets == 1 || len(packet) < s.MinPacket {
+		s.MinPacket = len(packet)
+	}
+	if len(packet) > s.MaxPacket {
+		s.MaxPacket = len(packet)
+	}
+	rc.adjust()
+	return packet, true
+}
+
+// adjust sets the bitrate for the next frame.
+func (rc *rateControl) adjust() {
+	switch {
+	case rc.budget > 0:
+		var frames = rc.frames - rc.stats.Frames
+		if frames <= 0 {
+			return
+		}
+		// 剩余预算按权重分配给剩余的帧(没有权重时平均分配)
+		var perFrame = float64(rc.budget-rc.stats.Payload) * rc.share()
+		rc.setBitRate(perFrame * 8 * 1000 / FRAME_LENGTH_MS / rc.scale)
+	case rc.cbr > 0:
+		var packetMs = float64(rc.control.packetSize) * 1000 / float64(rc.control.API_sampleRate)
+		rc.setBitRate(float64(rc.cbr) * 8 * 1000 / packetMs * cbrMargin / rc.scale)
+	}
+}
+
+func (rc *rateControl) setBitRate(bps float64) {
+	bps = math.Max(C.MIN_TARGET_RATE_BPS, math.Min(C.MAX_TARGET_RATE_BPS, bps))
+	rc.control.bitRate = C.SKP_int32(bps)
+}
+
+// frameCount returns the number of frames(20ms) encoded for pcm bytes of input,
+// the last partial frame is padded, and the last packet is filled with silent frames.
+// 编码 pcm 字节数的输入需要的帧数: 最后不足一帧的部分补零, 最后一个数据包用静音帧填满
+func frameCount(bytes int, cfg *EncodeCfg) int {
+	var (
+		frameSize = FRAME_LENGTH_MS * cfg.SampleRate / 1000 * 2
+		perPacket = cfg.PacketSizeMs / FRAME_LENGTH_MS
+		frames    = (bytes + frameSize - 1) / frameSize
+	)
+	return (frames + perPacket - 1) / perPacket * perPacket
+}
+
+// containerOverhead estimates the bytes of the output other than the packets.
+// 估算输出中除数据包以外的字节数(文件头、长度前缀、footer、元数据和标签等)
+func containerOverhead(cfg *EncodeCfg, frames int) int64 {
+	var packets = frames / (cfg.PacketSizeMs / FRAME_LENGTH_MS)
+	switch {
+	case cfg.Ogg:
+		// ID header 页 + 每个包一个 lacing value + 每页的页头
+		var pages = packets/oggPagePacket + 1
+		return int64(27+1+oggHeadLen+len(Version())) + int64(packets) + int64(pages*27)
+	case cfg.PacketLengths != nil:
+		return 0
+	}
+	prefix, _ := newLengthPrefix(cfg.LengthSize, cfg.LengthBigEndian)
+	var overhead = int64(packets * prefix.normalize().size)
+	if !cfg.NoHeader {
+		overhead += int64(HeaderLen)
+		if cfg.Stx {
+			overhead++
+		}
+	}
+	if !cfg.Stx && !cfg.NoHeader && prefix.hasFooter() {
+		overhead += int64(prefix.normalize().size)
+		// footer 之后的元数据和标签
+		if cfg.Metadata {
+			overhead += chunkHeaderLen + metadataChunkLen
+		}
+		if len(cfg.Tags) > 0 {
+			overhead += int64(chunkHeaderLen + len(cfg.Tags.marshal()))
+		}
+	}
+	return overhead
+}
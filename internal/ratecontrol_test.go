@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTargetSize(t *testing.T) {
+	var pcm = noisePCM(defaultSampleRate * 4)
+	for _, size := range []int64{6000, 10000, 20000} {
+		var stats EncodeStats
+		out, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) {
+			ec.TargetSize = size
+			ec.Stats = &stats
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(out)) > size || int64(len(out)) < size*8/10 {
+			t.Errorf("target size %d: got %d bytes", size, len(out))
+		}
+		if stats.Bytes != int64(len(out)) || stats.Frames != 200 {
+			t.Errorf("target size %d: unexpected stats %+v", size, stats)
+		}
+	}
+	// 多帧数据包: 按包内各帧的目标码率之和估计实际码率
+	var stats EncodeStats
+	out, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) {
+		ec.TargetSize, ec.PacketSizeMs, ec.Stats = 10000, 60, &stats
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) > 10000 || len(out) < 10000*8/10 {
+		t.Errorf("target size 10000 with 60ms packets: got %d bytes", len(out))
+	}
+	// footer 之后的元数据和标签也计入目标大小
+	var tags = Tags{"transcript": strings.Repeat("你好, world ", 200)}
+	out, err = Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) {
+		ec.TargetSize, ec.Stx, ec.Metadata, ec.Tags = 6000, false, true, tags
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) > 6000 || len(out) < 6000*8/10 {
+		t.Errorf("target size 6000 with metadata and tags: got %d bytes", len(out))
+	}
+	if got, err := ReadTags(bytes.NewReader(out)); err != nil || got["transcript"] != tags["transcript"] {
+		t.Errorf("tags=%v, err=%v", got, err)
+	}
+	if _, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) { ec.TargetSize = 1000 }); err == nil {
+		t.Errorf("expected error when target size is too small")
+	}
+}
+
+func TestCBR(t *testing.T) {
+	var (
+		pcm     = noisePCM(defaultSampleRate * 2)
+		lengths []int
+		stats   EncodeStats
+	)
+	packets, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) {
+		ec.CBRPacketSize = 60
+		ec.PacketLengths = &lengths
+		ec.Stats = &stats
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range lengths {
+		if n != 60 {
+			t.Errorf("packet %d: size %d, want 60", i, n)
+		}
+	}
+	if stats.Packets != len(lengths) || stats.Oversize != 0 || stats.MinPacket != 60 || stats.MaxPacket != 60 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	// 补零后仍然可以正常解码
+	got, err := Decode(bytes.NewReader(packets), func(dc *DecodeCfg) { dc.PacketLengths = lengths })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(pcm) {
+		t.Errorf("decoded %d bytes, want %d", len(got), len(pcm))
+	}
+}
+
+func TestCBRPaddingDecode(t *testing.T) {
+	var pcm = noisePCM(defaultSampleRate * 2)
+	for _, ms := range []int{20, 60} {
+		for _, fec := range []bool{false, true} {
+			var lengths []int
+			packets, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) {
+				ec.PacketSizeMs, ec.UseInBandFEC, ec.PacketLossPct = ms, fec, 20
+				ec.PacketLengths = &lengths
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var size int
+			for _, n := range lengths {
+				if n > size {
+					size = n
+				}
+			}
+			size += 16
+			// 补零到相同大小, 每 5 个包丢一个(丢包时从后面补零的包中查找冗余数据)
+			var (
+				plain, padded    bytes.Buffer
+				plainLen, padLen []int
+				offset           int
+			)
+			for i, n := range lengths {
+				var packet = packets[offset : offset+n]
+				offset += n
+				if i%5 == 3 {
+					plainLen, padLen = append(plainLen, 0), append(padLen, 0)
+					continue
+				}
+				plain.Write(packet)
+				padded.Write(packet)
+				padded.Write(make([]byte, size-n))
+				plainLen, padLen = append(plainLen, n), append(padLen, size)
+			}
+			want, err := Decode(&plain, func(dc *DecodeCfg) { dc.PacketLengths = plainLen })
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(&padded, func(dc *DecodeCfg) { dc.PacketLengths = padLen })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("packet %dms, fec=%v: padded packets decode differently", ms, fec)
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 没有文件头的 silk 数据
+// 有些抓包/录音工具会去掉 #!SILK_V3 文件头, 只保存 [长度][内容] 数据块,
+// 长度前缀也可能是 1/4 字节或大端序; 甚至只保存数据包本身, 每个包的长度另外记录.
+
+const defaultLengthSize = 2 // 长度前缀默认 2 字节小端序
+
+// lengthPrefix is the length prefix of each block: 1, 2 or 4 bytes, little or big endian.
+// The zero value is the silk v3 one: 2 bytes little endian.
+// 数据块的长度前缀, 零值表示 silk v3 的 2 字节小端序
+type lengthPrefix struct {
+	size  int
+	order binary.ByteOrder
+}
+
+func newLengthPrefix(size int, bigEndian bool) (lengthPrefix, error) {
+	if size == 0 {
+		size = defaultLengthSize
+	}
+	if size != 1 && size != 2 && size != 4 {
+		return lengthPrefix{}, fmt.Errorf("invalid length prefix size: %d, should be 1, 2 or 4", size)
+	}
+	var p = lengthPrefix{size: size, order: binary.LittleEndian}
+	if bigEndian {
+		p.order = binary.BigEndian
+	}
+	return p, nil
+}
+
+func (p lengthPrefix) normalize() lengthPrefix {
+	if p.size == 0 {
+		p.size = defaultLengthSize
+	}
+	if p.order == nil {
+		p.order = binary.LittleEndian
+	}
+	return p
+}
+
+// read reads the block size, a negative size means the footer.
+// 1 字节的长度是无符号的(没有 footer), 2/4 字节的长度是有符号的, 负数表示 footer
+func (p lengthPrefix) read(reader io.Reader) (int, error) {
+	p = p.normalize()
+	var buf = make([]byte, p.size)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return 0, err
+	}
+	switch p.size {
+	case 1:
+		return int(buf[0]), nil
+	case 2:
+		return int(int16(p.order.Uint16(buf))), nil
+	}
+	return int(int32(p.order.Uint32(buf))), nil
+}
+
+// put encodes n as the length prefix.
+func (p lengthPrefix) put(n int) ([]byte, error) {
+	p = p.normalize()
+	var buf = make([]byte, p.size)
+	switch p.size {
+	case 1:
+		if n < 0 || n > 0xff {
+			return nil, fmt.Errorf("block size %d overflows 1 byte length prefix", n)
+		}
+		buf[0] = byte(n)
+	case 2:
+		p.order.PutUint16(buf, uint16(int16(n)))
+	default:
+		p.order.PutUint32(buf, uint32(int32(n)))
+	}
+	return buf, nil
+}
+
+// hasFooter reports whether the footer(size -1) can be represented.
+func (p lengthPrefix) hasFooter() bool {
+	return p.normalize().size > 1
+}
+
+// bareReader reads bare packets without length prefix, the length of each packet is given by lengths.
+// A zero length means the packet is lost.
+// 读取没有长度前缀的数据包, 每个包的长度由 lengths 给出, 长度为 0 表示丢包
+type bareReader struct {
+	reader  io.Reader
+	lengths []int
+	index   int
+}
+
+func (r *bareReader) ReadPacket() ([]byte, error) {
+	if r.index >= len(r.lengths) {
+		return nil, io.EOF
+	}
+	var n = r.lengths[r.index]
+	r.index++
+	if n < 0 || n > maxDecodeBlockSize {
+		return nil, fmt.Errorf("invalid packet length: packet=%d, length=%d", r.index, n)
+	}
+	var in = make([]byte, n)
+	if _, err := io.ReadFull(r.reader, in); err != nil {
+		if errors.Is(err, io.EOF) {
+			log("packet=%d, EOF when read bare packet", r.index)
+			return nil, io.EOF
+		}
+		warn("packet=%d, read bare packet err=%+v", r.index, err)
+		return nil, fmt.Errorf("failed to read packet %d: %w", r.index, err)
+	}
+	return in, nil
+}
+
+// bareWriter writes packets without length prefix, and appends the length of each packet to lengths.
+// 写入没有长度前缀的数据包, 每个包的长度追加到 lengths 中
+type bareWriter struct {
+	out     io.Writer
+	lengths *[]int
+}
+
+func (w *bareWriter) WritePacket(payload []byte) error {
+	if _, err := w.out.Write(payload); err != nil {
+		warn("failed to write packet, err=%+v", err)
+		return fmt.Errorf("failed to write packet: %w", err)
+	}
+	*w.lengths = append(*w.lengths, len(payload))
+	return nil
+}
+
+func (w *bareWriter) Close() error { return nil }
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHeaderless(t *testing.T) {
+	pcm, err := os.ReadFile("../cmd/testdata/hao.decode.pcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	silk, err := Encode(bytes.NewReader(pcm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Decode(bytes.NewReader(silk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lengths []int
+	var tests = []struct {
+		name   string
+		encode func(*EncodeCfg)
+		decode func(*DecodeCfg)
+	}{
+		{"no header", func(ec *EncodeCfg) { ec.NoHeader = true }, func(dc *DecodeCfg) { dc.NoHeader = true }},
+		{
+			"4 bytes big endian",
+			func(ec *EncodeCfg) { ec.NoHeader, ec.LengthSize, ec.LengthBigEndian = true, 4, true },
+			func(dc *DecodeCfg) { dc.NoHeader, dc.LengthSize, dc.LengthBigEndian = true, 4, true },
+		},
+		{
+			"header with 1 byte",
+			func(ec *EncodeCfg) { ec.LengthSize = 1 },
+			func(dc *DecodeCfg) { dc.LengthSize = 1 },
+		},
+		{"bare packets", func(ec *EncodeCfg) { ec.PacketLengths = &lengths }, func(dc *DecodeCfg) { dc.PacketLengths = lengths }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Encode(bytes.NewReader(pcm), tt.encode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(data, []byte(Header)) == (tt.name != "header with 1 byte") {
+				t.Errorf("unexpected file header: %q", data[:16])
+			}
+			got, err := Decode(bytes.NewReader(data), tt.decode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("decoded len=%d, want=%d", len(got), len(want))
+			}
+		})
+	}
+
+	// 不指定选项时无法解码
+	data, _ := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) { ec.NoHeader = true })
+	if _, err = Decode(bytes.NewReader(data)); err == nil {
+		t.Errorf("expected error when decode headerless stream without option")
+	}
+	if _, err = Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) { ec.LengthSize = 3 }); err == nil {
+		t.Errorf("expected error of invalid length prefix size")
+	}
+
+	// 损坏的长度前缀在分配内存之前报错
+	var corrupt = append([]byte{0xff, 0xff, 0xff, 0x7f}, make([]byte, 16)...)
+	var decode4 = func(dc *DecodeCfg) { dc.NoHeader, dc.LengthSize = true, 4 }
+	if _, err = Decode(bytes.NewReader(corrupt), decode4); err == nil || !strings.Contains(err.Error(), "invalid block size") {
+		t.Errorf("expected invalid block size error, got %v", err)
+	}
+	var bare = func(dc *DecodeCfg) { dc.PacketLengths = []int{1 << 30} }
+	if _, err = Decode(bytes.NewReader(corrupt), bare); err == nil {
+		t.Errorf("expected error of invalid packet length")
+	}
+
+	// 超过编码器上限(1250)但在解码器上限之内的数据块仍然可以解码(SDK 按丢包处理)
+	var large = append([]byte(Header), silk[len(Header):len(Header)+2+int(silk[len(Header)])]...)
+	large = append(large, 0x14, 0x05) // 1300
+	large = append(large, make([]byte, 1300)...)
+	got, err := Decode(bytes.NewReader(large))
+	if err != nil {
+		t.Fatalf("decode 1300 bytes block: %v", err)
+	}
+	if len(got) != 2*FRAME_LENGTH_MS*defaultSampleRate/1000*2 {
+		t.Errorf("decode 1300 bytes block: got %d bytes", len(got))
+	}
+}
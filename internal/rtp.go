@@ -0,0 +1,223 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// RTP 格式说明
+// https://datatracker.ietf.org/doc/html/rfc3550#section-5.1
+// SILK 的 RTP 负载就是一个完整的 silk 数据包(不含长度前缀)
+// https://datatracker.ietf.org/doc/html/draft-spittka-silk-payload-format
+
+const (
+	rtpVersion   = 2
+	rtpHeaderLen = 12
+	// 序号缺口超过这个值认为是流重启等异常情况, 不再当作丢包处理(3000 个包=60s)
+	maxRTPGap = 3000
+)
+
+// RTPStream describes a RTP stream found in a capture file.
+// 抓包文件中的一路 RTP 流
+type RTPStream struct {
+	SSRC        uint32
+	PayloadType int
+	SrcPort     uint16
+	DstPort     uint16
+	Packets     int
+}
+
+type rtpPacket struct {
+	SSRC        uint32
+	PayloadType int
+	Seq         uint16
+	Timestamp   uint32
+	SrcPort     uint16
+	DstPort     uint16
+	Payload     []byte
+}
+
+// parseRTP parses an UDP payload as RTP packet.
+// 将 UDP 数据解析为 RTP 包
+func parseRTP(udp udpPayload) (rtpPacket, bool) {
+	var data = udp.Data
+	if len(data) < rtpHeaderLen || data[0]>>6 != rtpVersion {
+		return rtpPacket{}, false
+	}
+	var (
+		padding   = data[0]&0x20 != 0
+		extension = data[0]&0x10 != 0
+		csrcCount = int(data[0] & 0x0f)
+		pt        = int(data[1] & 0x7f)
+		offset    = rtpHeaderLen + csrcCount*4
+	)
+	if data[1] >= 200 && data[1] <= 207 { // RTCP 和 RTP 复用端口时, 第二个字节是 RTCP 包类型
+		return rtpPacket{}, false
+	}
+	if extension {
+		if len(data) < offset+4 {
+			return rtpPacket{}, false
+		}
+		offset += 4 + int(binary.BigEndian.Uint16(data[offset+2:]))*4
+	}
+	var end = len(data)
+	if padding {
+		end -= int(data[len(data)-1])
+	}
+	if offset > end {
+		return rtpPacket{}, false
+	}
+	return rtpPacket{
+		SSRC:        binary.BigEndian.Uint32(data[8:]),
+		PayloadType: pt,
+		Seq:         binary.BigEndian.Uint16(data[2:]),
+		Timestamp:   binary.BigEndian.Uint32(data[4:]),
+		SrcPort:     udp.SrcPort,
+		DstPort:     udp.DstPort,
+		Payload:     data[offset:end],
+	}, true
+}
+
+// readRTP reads all RTP packets in a pcap/pcapng capture file.
+func readRTP(src io.Reader) ([]rtpPacket, error) {
+	udps, err := readPcapUDP(src)
+	if err != nil {
+		return nil, err
+	}
+	var packets []rtpPacket
+	for _, udp := range udps {
+		if p, ok := parseRTP(udp); ok {
+			packets = append(packets, p)
+		}
+	}
+	return packets, nil
+}
+
+// PcapStreams lists RTP streams in a pcap/pcapng capture file.
+// 列出抓包文件中的所有 RTP 流
+func PcapStreams(src io.Reader) ([]RTPStream, error) {
+	packets, err := readRTP(src)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		streams []RTPStream
+		index   = map[[2]uint32]int{}
+	)
+	for _, p := range packets {
+		var key = [2]uint32{p.SSRC, uint32(p.PayloadType)}
+		i, ok := index[key]
+		if !ok {
+			i = len(streams)
+			index[key] = i
+			streams = append(streams, RTPStream{
+				SSRC:        p.SSRC,
+				PayloadType: p.PayloadType,
+				SrcPort:     p.SrcPort,
+				DstPort:     p.DstPort,
+			})
+		}
+		streams[i].Packets++
+	}
+	return streams, nil
+}
+
+// DecodePcap decodes the silk RTP stream identified by ssrc in a pcap/pcapng capture file.
+// Packets are ordered by sequence number, missing packets are treated as lost.
+// 解码抓包文件中指定 SSRC 的 silk RTP 流, 按序号排序, 缺失的包按丢包处理
+func DecodePcap(src io.Reader, ssrc uint32, opts ...DecodeOpt) ([]byte, error) {
+	var cfg = buildDecodeCfg(opts...)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	log("decode pcap option: ssrc=%d(%#x), %#v", ssrc, ssrc, cfg)
+
+	packets, err := readRTP(src)
+	if err != nil {
+		return nil, err
+	}
+	var stream []rtpPacket
+	for _, p := range packets {
+		if p.SSRC == ssrc && (cfg.PayloadType < 0 || p.PayloadType == cfg.PayloadType) {
+			stream = append(stream, p)
+		}
+	}
+	if len(stream) == 0 {
+		return nil, fmt.Errorf("no RTP packet found, ssrc=%d(%#x), payload type=%d", ssrc, ssrc, cfg.PayloadType)
+	}
+	log("found %d RTP packets", len(stream))
+
+	/* Create decoder */
+	var psDec, free = malloc(getDecoderSize())
+	defer free()
+
+	/* Reset decoder */
+	initDecoder(psDec)
+
+	out := &bytes.Buffer{}
+	if err := doDecode(&rtpReader{packets: orderRTP(stream)}, psDec, cfg.SampleRate, out); err != nil {
+		return nil, err
+	}
+	return cfg.postProcess(out.Bytes()), nil
+}
+
+// orderRTP orders packets of one stream by sequence number, drops duplicates,
+// and inserts empty(lost) packets into the gaps.
+// 按序号排序, 去重, 缺失的序号补上空包(表示丢包)
+func orderRTP(stream []rtpPacket) [][]byte {
+	type seqPacket struct {
+		seq     int64 // 扩展序号, 处理 16 位序号回绕
+		payload []byte
+	}
+	var (
+		list    = make([]seqPacket, 0, len(stream))
+		prevSeq = stream[0].Seq
+		extSeq  int64
+	)
+	for _, p := range stream {
+		extSeq += int64(int16(p.Seq - prevSeq))
+		prevSeq = p.Seq
+		list = append(list, seqPacket{seq: extSeq, payload: p.Payload})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].seq < list[j].seq })
+
+	var result [][]byte
+	for i, p := range list {
+		if i > 0 {
+			var gap = p.seq - list[i-1].seq
+			if gap == 0 {
+				log("duplicate RTP packet, seq=%d", p.seq)
+				continue
+			}
+			if gap > maxRTPGap {
+				warn("RTP sequence jumps from %d to %d, not treat as lost", list[i-1].seq, p.seq)
+				gap = 1
+			}
+			for lost := int64(1); lost < gap; lost++ {
+				result = append(result, nil)
+			}
+			if gap > 1 {
+				log("RTP packets lost, seq=(%d, %d)", list[i-1].seq, p.seq)
+			}
+		}
+		result = append(result, p.payload)
+	}
+	return result
+}
+
+// rtpReader reads ordered RTP payloads as silk packets.
+type rtpReader struct {
+	packets [][]byte
+}
+
+func (r *rtpReader) ReadPacket() ([]byte, error) {
+	if len(r.packets) == 0 {
+		return nil, io.EOF
+	}
+	var packet = r.packets[0]
+	r.packets = r.packets[1:]
+	return packet, nil
+}
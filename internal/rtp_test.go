@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+// readTestPackets reads silk packets of the test file.
+func readTestPackets(t *testing.T) [][]byte {
+	f, err := os.Open("../cmd/testdata/hao.amr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var reader = bufio.NewReader(f)
+	if err = checkHeader(reader); err != nil {
+		t.Fatal(err)
+	}
+	var r = &silkReader{reader: reader}
+	var packets [][]byte
+	for {
+		p, err := r.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, p)
+	}
+	return packets
+}
+
+// udpFrame builds an Ethernet/IPv4/UDP frame carrying a RTP packet.
+func udpFrame(seq uint16, ssrc uint32, payload []byte) []byte {
+	var rtp = make([]byte, rtpHeaderLen, rtpHeaderLen+len(payload))
+	rtp[0] = rtpVersion << 6
+	rtp[1] = 96
+	binary.BigEndian.PutUint16(rtp[2:], seq)
+	binary.BigEndian.PutUint32(rtp[4:], uint32(seq)*480)
+	binary.BigEndian.PutUint32(rtp[8:], ssrc)
+	rtp = append(rtp, payload...)
+
+	var udp = make([]byte, 8, 8+len(rtp))
+	binary.BigEndian.PutUint16(udp, 5004)
+	binary.BigEndian.PutUint16(udp[2:], 5006)
+	binary.BigEndian.PutUint16(udp[4:], uint16(8+len(rtp)))
+	udp = append(udp, rtp...)
+
+	var ip = make([]byte, 20, 20+len(udp))
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(udp)))
+	ip[8] = 64
+	ip[9] = 17
+	ip = append(ip, udp...)
+
+	var eth = make([]byte, 14, 14+len(ip))
+	binary.BigEndian.PutUint16(eth[12:], 0x0800)
+	return append(eth, ip...)
+}
+
+func writePcap(frames [][]byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, []uint32{pcapMagicMicro, 0x00040002, 0, 0, 65535, linkTypeEther})
+	for i, frame := range frames {
+		binary.Write(&buf, binary.LittleEndian, []uint32{uint32(i), 0, uint32(len(frame)), uint32(len(frame))})
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
+
+func writePcapng(frames [][]byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, []uint32{pcapngBlockSHB, 28, pcapngByteOrder, 1, 0xffffffff, 0xffffffff, 28})
+	binary.Write(&buf, binary.LittleEndian, []uint32{pcapngBlockIDB, 20, linkTypeEther, 65535, 20})
+	for i, frame := range frames {
+		var padded = (len(frame) + 3) / 4 * 4
+		var total = uint32(32 + padded)
+		binary.Write(&buf, binary.LittleEndian, []uint32{pcapngBlockEPB, total, 0, 0, uint32(i), uint32(len(frame)), uint32(len(frame))})
+		buf.Write(frame)
+		buf.Write(make([]byte, padded-len(frame)))
+		binary.Write(&buf, binary.LittleEndian, total)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodePcap(t *testing.T) {
+	var packets = readTestPackets(t)
+	const ssrc = 0x1234
+	var (
+		start  = uint16(65530) // 序号回绕
+		frames [][]byte
+	)
+	for i, p := range packets {
+		if i == 5 { // 丢包
+			continue
+		}
+		frames = append(frames, udpFrame(start+uint16(i), ssrc, p))
+		if i == 8 { // 重复包
+			frames = append(frames, udpFrame(start+uint16(i), ssrc, p))
+		}
+		if i == 3 { // 其他流
+			frames = append(frames, udpFrame(uint16(i), ssrc+1, p))
+		}
+	}
+	frames[10], frames[11] = frames[11], frames[10] // 乱序
+
+	for name, file := range map[string][]byte{"pcap": writePcap(frames), "pcapng": writePcapng(frames)} {
+		t.Run(name, func(t *testing.T) {
+			streams, err := PcapStreams(bytes.NewReader(file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(streams) != 2 || streams[0].SSRC != ssrc || streams[0].Packets != len(packets) {
+				t.Fatalf("unexpected streams: %+v", streams)
+			}
+			pcm, err := DecodePcap(bytes.NewReader(file), ssrc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 24000Hz, 每个包 20ms, 丢失的包也会补偿输出
+			if want := len(packets) * 480 * 2; len(pcm) != want {
+				t.Errorf("decoded %d bytes, want %d", len(pcm), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// 采样格式转换: silk 编解码使用 16 位小端序 pcm, 这里提供 int16/float32 类型的接口, 以及大端序 pcm 的输入输出.
+
+// DecodeInt16 decodes src to 16bit samples, the byte order option is ignored.
+// 解码为 int16 采样点(忽略字节序设置)
+func DecodeInt16(src io.Reader, opts ...DecodeOpt) ([]int16, error) {
+	pcm, err := Decode(src, append(opts, littleEndianOutput)...)
+	if err != nil {
+		return nil, err
+	}
+	var samples = make([]int16, len(pcm)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+	}
+	return samples, nil
+}
+
+// DecodeFloat32 decodes src to float samples in [-1, 1), the byte order option is ignored.
+// 解码为 float32 采样点, 取值范围 [-1, 1)(忽略字节序设置)
+func DecodeFloat32(src io.Reader, opts ...DecodeOpt) ([]float32, error) {
+	pcm, err := Decode(src, append(opts, littleEndianOutput)...)
+	if err != nil {
+		return nil, err
+	}
+	var samples = make([]float32, len(pcm)/2)
+	for i := range samples {
+		samples[i] = float32(int16(binary.LittleEndian.Uint16(pcm[i*2:]))) / 32768
+	}
+	return samples, nil
+}
+
+func littleEndianOutput(dc *DecodeCfg) { dc.PCMBigEndian = false }
+
+// EncodeInt16 encodes 16bit samples(interleaved when Channels > 1), the byte order option is ignored.
+// 编码 int16 采样点(多声道时交错排列), 忽略字节序设置
+func EncodeInt16(samples []int16, opts ...EncodeOpt) ([]byte, error) {
+	var pcm = make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(s))
+	}
+	return Encode(bytes.NewReader(pcm), append(opts, littleEndianInput)...)
+}
+
+// EncodeFloat32 encodes float samples(interleaved when Channels > 1),
+// samples out of [-1, 1) are clipped, and TPDF dither is added when Dither is set.
+// 编码 float32 采样点(多声道时交错排列), 超出 [-1, 1) 的值会被削波, 设置 Dither 时在量化前添加三角分布抖动
+func EncodeFloat32(samples []float32, opts ...EncodeOpt) ([]byte, error) {
+	var (
+		cfg     = buildCfg(opts...)
+		pcm     = make([]byte, len(samples)*2)
+		dither  ditherNoise
+		clipped int
+	)
+	for i, s := range samples {
+		var v = float64(s) * 32768
+		if cfg.Dither {
+			v += dither.next()
+		}
+		v = math.Round(v)
+		if v > math.MaxInt16 || v < math.MinInt16 {
+			clipped++
+			v = math.Max(math.MinInt16, math.Min(math.MaxInt16, v))
+		}
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(v)))
+	}
+	if clipped > 0 {
+		warn("%d samples are clipped", clipped)
+	}
+	return Encode(bytes.NewReader(pcm), append(opts, littleEndianInput)...)
+}
+
+func littleEndianInput(ec *EncodeCfg) { ec.PCMBigEndian = false }
+
+// ditherNoise generates TPDF(triangular) noise of ±1 LSB, a fixed seed keeps the output reproducible.
+// 生成 ±1 LSB 的三角分布噪声, 使用固定的种子以便输出可以复现
+type ditherNoise struct {
+	state uint32
+}
+
+func (d *ditherNoise) next() float64 {
+	return d.uniform() - d.uniform()
+}
+
+func (d *ditherNoise) uniform() float64 {
+	if d.state == 0 {
+		d.state = 0x2545f491
+	}
+	// xorshift32
+	d.state ^= d.state << 13
+	d.state ^= d.state >> 17
+	d.state ^= d.state << 5
+	return float64(d.state) / (1 << 32)
+}
+
+// swapBytes converts 16bit pcm between little endian and big endian in place.
+// 转换 16 位 pcm 的字节序
+func swapBytes(pcm []byte) {
+	for i := 0; i+1 < len(pcm); i += 2 {
+		pcm[i], pcm[i+1] = pcm[i+1], pcm[i]
+	}
+}
+
+// byteSwapper converts big endian 16bit pcm input to little endian.
+type byteSwapper struct {
+	reader io.Reader
+	odd    []byte // 上一次读取剩下的奇数字节
+}
+
+func (r *byteSwapper) Read(p []byte) (int, error) {
+	if len(p) < 2 {
+		return 0, fmt.Errorf("buffer too small: %d", len(p))
+	}
+	var n = copy(p, r.odd)
+	r.odd = nil
+	m, err := io.ReadAtLeast(r.reader, p[n:len(p)/2*2], 1)
+	n += m
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	if n%2 == 1 && err == nil { // 保留不完整的采样点
+		n--
+		r.odd = []byte{p[n]}
+	}
+	swapBytes(p[:n/2*2])
+	if n == 0 && err != nil {
+		return 0, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSampleFormat(t *testing.T) {
+	var pcm = sinePCM(defaultSampleRate, 1, 0.3)
+	want, err := Encode(bytes.NewReader(pcm))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ints   = make([]int16, len(pcm)/2)
+		floats = make([]float32, len(pcm)/2)
+		big    = append([]byte(nil), pcm...)
+	)
+	for i := range ints {
+		ints[i] = int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+		floats[i] = float32(ints[i]) / 32768
+	}
+	swapBytes(big)
+	for name, encode := range map[string]func() ([]byte, error){
+		"int16":   func() ([]byte, error) { return EncodeInt16(ints) },
+		"float32": func() ([]byte, error) { return EncodeFloat32(floats) },
+		"big endian": func() ([]byte, error) {
+			// 每次只读 1 个字节, 测试不完整的采样点
+			return Encode(iotest.OneByteReader(bytes.NewReader(big)), func(ec *EncodeCfg) { ec.PCMBigEndian = true })
+		},
+	} {
+		got, err := encode()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: encoded result differs", name)
+		}
+	}
+
+	decoded, err := Decode(bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotInts, err := DecodeInt16(bytes.NewReader(want), func(dc *DecodeCfg) { dc.PCMBigEndian = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotFloats, err := DecodeFloat32(bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotInts) != len(decoded)/2 || len(gotFloats) != len(gotInts) {
+		t.Fatalf("decoded %d int16 and %d float32, want %d", len(gotInts), len(gotFloats), len(decoded)/2)
+	}
+	for i, s := range gotInts {
+		if s != int16(binary.LittleEndian.Uint16(decoded[i*2:])) || gotFloats[i] != float32(s)/32768 {
+			t.Fatalf("sample %d differs", i)
+		}
+	}
+
+	bigDecoded, err := Decode(bytes.NewReader(want), func(dc *DecodeCfg) { dc.PCMBigEndian = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapBytes(bigDecoded)
+	if !bytes.Equal(bigDecoded, decoded) {
+		t.Errorf("big endian output differs")
+	}
+	d, err := NewDecoder(bytes.NewReader(want), func(dc *DecodeCfg) { dc.PCMBigEndian = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	streamed, err := io.ReadAll(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapBytes(streamed)
+	if !bytes.Equal(streamed, decoded) {
+		t.Errorf("big endian streaming output differs")
+	}
+}
+
+func TestEncodeFloat32Clip(t *testing.T) {
+	var samples = make([]float32, defaultSampleRate)
+	for i := range samples {
+		samples[i] = 2 * float32(math.Sin(2*math.Pi*440*float64(i)/defaultSampleRate))
+	}
+	if _, err := EncodeFloat32(samples, func(ec *EncodeCfg) { ec.Dither = true }); err != nil {
+		t.Fatal(err)
+	}
+
+	// 抖动噪声为 ±1 LSB 的三角分布, 均值为 0
+	var (
+		dither ditherNoise
+		sum    float64
+	)
+	for i := 0; i < 100000; i++ {
+		var v = dither.next()
+		if v <= -1 || v >= 1 {
+			t.Fatalf("dither out of range: %v", v)
+		}
+		sum += v
+	}
+	if mean := sum / 100000; math.Abs(mean) > 0.01 {
+		t.Errorf("dither mean=%v", mean)
+	}
+}
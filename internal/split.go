@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// 按静音将长录音切分为多个 silk 文件.
+// 根据数据包 TOC 中的 VAD 标记找到停顿(pcm 输入先编码, 编码器内部使用 SKP_Silk_VAD 计算 VAD 标记),
+// 每段不超过最大时长, 尽量在最靠后的停顿中间切分, 没有停顿时在最大时长处切分.
+
+const (
+	defaultMaxDuration = 60 * time.Second // 微信语音最长 60 秒
+	defaultMinSilence  = 300 * time.Millisecond
+)
+
+type SplitCfg struct {
+	MaxDuration time.Duration // max duration of each segment, default 60s
+	MinSilence  time.Duration // min duration of a pause to split at, default 300ms
+	Encode      []EncodeOpt   // encode options for pcm input and the output format(Stx, Ogg...) of segments
+}
+
+type SplitOpt func(*SplitCfg)
+
+func buildSplitCfg(opts ...SplitOpt) *SplitCfg {
+	var cfg = &SplitCfg{
+		MaxDuration: defaultMaxDuration,
+		MinSilence:  defaultMinSilence,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// splitPacket is a packet with its duration and VAD flag.
+type splitPacket struct {
+	data     []byte
+	duration time.Duration
+	speech   bool
+}
+
+// Split splits silk or pcm input into segments on silence, each segment is a complete silk file.
+// 按静音切分 silk 或 pcm 输入, 每段都是完整的 silk 文件
+func Split(src io.Reader, opts ...SplitOpt) ([][]byte, error) {
+	var cfg = buildSplitCfg(opts...)
+	if cfg.MaxDuration < FRAME_LENGTH_MS*time.Millisecond {
+		return nil, fmt.Errorf("invalid max duration: %v", cfg.MaxDuration)
+	}
+	var encCfg = buildCfg(cfg.Encode...)
+	if err := encCfg.Validate(); err != nil {
+		return nil, err
+	}
+	log("split option: %#v, encode option: %#v", cfg, encCfg)
+
+	packets, err := readSplitPackets(src, encCfg)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		segments [][]byte
+		outCfg   = *encCfg
+	)
+	outCfg.PacketLengths = nil // 每段都是完整的文件
+	for _, seg := range splitPoints(packets, cfg) {
+		var buf bytes.Buffer
+		out, err := newPacketWriter(&buf, &outCfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range packets[seg[0]:seg[1]] {
+			if err = out.WritePacket(p.data); err != nil {
+				return nil, err
+			}
+		}
+		if err = out.Close(); err != nil {
+			return nil, err
+		}
+		segments = append(segments, buf.Bytes())
+	}
+	return segments, nil
+}
+
+// readSplitPackets reads the packets of silk input, or encodes pcm input to packets.
+func readSplitPackets(src io.Reader, encCfg *EncodeCfg) ([]splitPacket, error) {
+	var reader = bufio.NewReader(src)
+	head, err := reader.Peek(sniffLen)
+	if len(head) == 0 {
+		return nil, fmt.Errorf("failed to peek file header: %w", err)
+	}
+	var (
+		list   = &packetList{}
+		format = DetectFormat(head)
+	)
+	log("split input format: %s", format)
+	switch {
+	case format.IsSilk():
+		packets, err := openPackets(reader, &DecodeCfg{})
+		if err != nil {
+			return nil, err
+		}
+		for {
+			packet, err := packets.ReadPacket()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			list.packets = append(list.packets, packet)
+		}
+	case format == FormatPCM || format == FormatUnknown: // 无法识别的数据按 pcm 处理
+		if err = encodePackets(reader, list, encCfg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, newFormatError(format, head)
+	}
+
+	var (
+		packets = make([]splitPacket, 0, len(list.packets))
+		frames  = 1 // 丢包时按上一个包的帧数计算
+	)
+	for _, data := range list.packets {
+		var p = splitPacket{data: data}
+		if toc, ok := readTOC(data); ok && toc.framesInPacket > 0 {
+			frames = int(toc.framesInPacket)
+			for i := 0; i < frames; i++ {
+				p.speech = p.speech || toc.vadFlags[i] != 0
+			}
+		}
+		p.duration = time.Duration(frames*FRAME_LENGTH_MS) * time.Millisecond
+		packets = append(packets, p)
+	}
+	return packets, nil
+}
+
+// splitPoints returns the [start, end) packet index of each segment.
+func splitPoints(packets []splitPacket, cfg *SplitCfg) (segments [][2]int) {
+	var (
+		start    int           // 当前段的第一个包
+		duration time.Duration // 当前段的时长
+		cut      = -1          // 当前段中最后一个足够长的停顿的中间位置
+		silence  time.Duration // 当前停顿的时长
+		pause    int           // 当前停顿的第一个包
+	)
+	for i := 0; i < len(packets); i++ {
+		var p = packets[i]
+		if duration+p.duration > cfg.MaxDuration && i > start {
+			var end = i // 没有停顿时在最大时长处切分
+			if cut > start {
+				end = cut
+			}
+			segments = append(segments, [2]int{start, end})
+			log("split segment: packets [%d, %d)", start, end)
+			// 从切分点重新计算
+			start, duration, cut, silence, pause = end, 0, -1, 0, end
+			i = end - 1
+			continue
+		}
+		duration += p.duration
+		if p.speech {
+			silence = 0
+			continue
+		}
+		if silence == 0 {
+			pause = i
+		}
+		silence += p.duration
+		if silence >= cfg.MinSilence {
+			cut = (pause + i + 1) / 2
+		}
+	}
+	if start < len(packets) {
+		segments = append(segments, [2]int{start, len(packets)})
+	}
+	return segments
+}
+
+// packetList collects encoded packets.
+type packetList struct {
+	packets [][]byte
+}
+
+func (l *packetList) WritePacket(payload []byte) error {
+	// doEncode 会复用 payload, 需要复制
+	l.packets = append(l.packets, append([]byte(nil), payload...))
+	return nil
+}
+
+func (l *packetList) Close() error { return nil }
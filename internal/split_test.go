@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitPoints(t *testing.T) {
+	// s=speech, _=silence, 每个包 20ms
+	build := func(vad string) []splitPacket {
+		var packets []splitPacket
+		for _, c := range vad {
+			packets = append(packets, splitPacket{duration: 20 * time.Millisecond, speech: c == 's'})
+		}
+		return packets
+	}
+	var cfg = &SplitCfg{MaxDuration: 200 * time.Millisecond, MinSilence: 60 * time.Millisecond}
+	var tests = []struct {
+		vad  string
+		want [][2]int
+	}{
+		{"sssss", [][2]int{{0, 5}}},
+		{"ssssssssssssss", [][2]int{{0, 10}, {10, 14}}},           // 没有停顿, 在最大时长处切分
+		{"sss____sssssssss", [][2]int{{0, 5}, {5, 15}, {15, 16}}}, // 在停顿中间切分
+		{"s___ss__ss____sssss", [][2]int{{0, 2}, {2, 12}, {12, 19}}},
+		{"ss__sssssssssss", [][2]int{{0, 10}, {10, 15}}}, // 停顿太短
+	}
+	for _, tt := range tests {
+		if got := splitPoints(build(tt.vad), cfg); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPoints(%s) = %v, want %v", tt.vad, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	pcm, err := os.ReadFile("../cmd/testdata/hao.decode.pcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments, err := Split(bytes.NewReader(pcm), func(sc *SplitCfg) {
+		sc.MaxDuration = time.Second
+		sc.Encode = []EncodeOpt{func(ec *EncodeCfg) { ec.Stx = true }}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) < 3 {
+		t.Fatalf("expected at least 3 segments, got %d", len(segments))
+	}
+	var total int
+	for i, seg := range segments {
+		if seg[0] != STX {
+			t.Errorf("segment %d should start with STX", i)
+		}
+		data, err := Decode(bytes.NewReader(seg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > defaultSampleRate*2 {
+			t.Errorf("segment %d is longer than 1s: %d bytes", i, len(data))
+		}
+		total += len(data)
+	}
+	// 每个包 20ms, 24000Hz 解码后是 960 字节
+	if want := len(pcm) / 960 * 960; total != want {
+		t.Errorf("total %d bytes, want %d", total, want)
+	}
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf8"
+)
+
+// 标签: footer 之后的 TAGS 扩展块, 保存发送者、会话 ID、转写文本等 key/value(UTF-8) 信息.
+// 内容格式: 按 key 排序的 ([uvarint 长度][key][uvarint 长度][value])*
+
+const tagsChunkID = "TAGS"
+
+// Tags are key/value(UTF-8) pairs stored after the footer of silk v3 file, e.g. sender, conversation id, transcript.
+// 保存在 silk v3 文件 footer 之后的标签
+type Tags map[string]string
+
+// validate checks the keys and values are valid UTF-8 and the keys are not empty.
+func (t Tags) validate() error {
+	var errs []error
+	for k, v := range t {
+		if k == "" || !utf8.ValidString(k) {
+			errs = append(errs, fmt.Errorf("invalid tag key %q", k))
+		}
+		if !utf8.ValidString(v) {
+			errs = append(errs, fmt.Errorf("invalid tag value of %q, should be UTF-8", k))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t Tags) marshal() []byte {
+	var keys = make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // 输出稳定
+	var data []byte
+	for _, k := range keys {
+		data = binary.AppendUvarint(data, uint64(len(k)))
+		data = append(data, k...)
+		data = binary.AppendUvarint(data, uint64(len(t[k])))
+		data = append(data, t[k]...)
+	}
+	return data
+}
+
+func parseTags(data []byte) (Tags, error) {
+	var (
+		tags = Tags{}
+		next = func() (string, error) {
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return "", errors.New("invalid tags chunk")
+			}
+			var s = string(data[n : n+int(size)])
+			data = data[n+int(size):]
+			return s, nil
+		}
+	)
+	for len(data) > 0 {
+		k, err := next()
+		if err != nil {
+			return nil, err
+		}
+		v, err := next()
+		if err != nil {
+			return nil, err
+		}
+		tags[k] = v
+	}
+	return tags, tags.validate()
+}
+
+// tags returns the tags in the trailer, nil when not present.
+func (t *trailer) tags() (Tags, error) {
+	for _, c := range t.chunks {
+		if c.id == tagsChunkID {
+			return parseTags(c.data)
+		}
+	}
+	return nil, nil
+}
+
+// ReadTags reads the tags of silk v3 file, nil is returned when there is no tag.
+// 读取 silk v3 文件的标签, 没有标签时返回 nil
+func ReadTags(src io.Reader) (Tags, error) {
+	t, err := readTrailer(src)
+	if err != nil {
+		return nil, err
+	}
+	return t.tags()
+}
+
+// WriteTags copies silk v3 file from src to dst, and replaces its tags, empty tags removes them.
+// The packets and other chunks are kept. The file must not have STX flag since tags are stored after the footer.
+// 复制 silk v3 文件并替换其中的标签(tags 为空时删除标签), 数据包和其他扩展块不变.
+// 标签保存在 footer 之后, 所以不支持没有 footer 的 STX 格式
+func WriteTags(src io.Reader, dst io.Writer, tags Tags) error {
+	if err := tags.validate(); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	switch format := DetectFormat(data); format {
+	case FormatSilk:
+	case FormatSilkStx:
+		return errors.New("tags are not supported by silk file with STX flag(no footer)")
+	default:
+		return newFormatError(format, data)
+	}
+	// 逐个跳过数据包, 找到 footer 的位置
+	var (
+		end    = len(Header)
+		reader = &silkReader{reader: bytes.NewReader(data[end:])}
+	)
+	for {
+		packet, err := reader.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		end += defaultLengthSize + len(packet)
+	}
+	// 没有 footer 时补上; 已有的扩展块除 TAGS 外原样保留, 无效的数据丢弃
+	footer, _ := lengthPrefix{}.put(-1)
+	var out = append(data[:end:end], footer...)
+	if _, err = dst.Write(out); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	for _, c := range reader.tail.chunks {
+		if c.id == tagsChunkID {
+			continue
+		}
+		if err = writeChunk(dst, c.id, c.data); err != nil {
+			return err
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return writeChunk(dst, tagsChunkID, tags.marshal())
+}
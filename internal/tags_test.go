@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	var (
+		pcm  = sinePCM(defaultSampleRate, 1, 0.3)
+		tags = Tags{"sender": "alice", "conversation": "c-42", "transcript": "你好, world\nsecond line"}
+	)
+	plain, err := Encode(bytes.NewReader(pcm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	silk, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) { ec.Tags = tags; ec.Metadata = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadTags(bytes.NewReader(silk))
+	if err != nil || !reflect.DeepEqual(got, tags) {
+		t.Fatalf("tags=%v, err=%v", got, err)
+	}
+	// 解码时跳过标签
+	want, _ := Decode(bytes.NewReader(plain))
+	if pcm, err := Decode(bytes.NewReader(silk)); err != nil || !bytes.Equal(pcm, want) {
+		t.Errorf("decode with tags: %d bytes, err=%v, want %d bytes", len(pcm), err, len(want))
+	}
+	if got, err = ReadTags(bytes.NewReader(plain)); err != nil || got != nil {
+		t.Errorf("no tags: tags=%v, err=%v", got, err)
+	}
+
+	// 替换标签, 数据包和元数据不变
+	var buf bytes.Buffer
+	if err = WriteTags(bytes.NewReader(silk), &buf, Tags{"sender": "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = ReadTags(bytes.NewReader(buf.Bytes())); err != nil || !reflect.DeepEqual(got, Tags{"sender": "bob"}) {
+		t.Errorf("replaced tags=%v, err=%v", got, err)
+	}
+	if m, err := ReadMetadata(bytes.NewReader(buf.Bytes())); err != nil || m == nil || m.Samples != int64(len(pcm)/2) {
+		t.Errorf("metadata should be kept: %+v, err=%v", m, err)
+	}
+	// 删除标签
+	var removed bytes.Buffer
+	if err = WriteTags(bytes.NewReader(buf.Bytes()), &removed, nil); err != nil {
+		t.Fatal(err)
+	}
+	var noTags bytes.Buffer
+	if err = WriteTags(bytes.NewReader(plain), &noTags, nil); err != nil || !bytes.Equal(noTags.Bytes(), plain) {
+		t.Errorf("remove tags of file without tags should not change it, err=%v", err)
+	}
+	if !bytes.HasPrefix(removed.Bytes(), plain) || removed.Len() != len(plain)+chunkHeaderLen+metadataChunkLen {
+		t.Errorf("removed tags: %d bytes, want %d", removed.Len(), len(plain)+chunkHeaderLen+metadataChunkLen)
+	}
+	// 没有 footer 时补上
+	buf.Reset()
+	if err = WriteTags(bytes.NewReader(plain[:len(plain)-2]), &buf, tags); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), plain) {
+		t.Errorf("footer should be appended")
+	}
+
+	if err = WriteTags(bytes.NewReader(append([]byte{STX}, plain...)), &buf, tags); err == nil {
+		t.Errorf("stx file should not support tags")
+	}
+	if err = WriteTags(bytes.NewReader(plain), &buf, Tags{"": "empty key"}); err == nil {
+		t.Errorf("empty key should be invalid")
+	}
+	if _, err = Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) { ec.Tags = tags; ec.Stx = true }); err == nil {
+		t.Errorf("tags without footer should be invalid")
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	var tags = Tags{"k": "v", "空": ""}
+	got, err := parseTags(tags.marshal())
+	if err != nil || !reflect.DeepEqual(got, tags) {
+		t.Errorf("tags=%v, err=%v", got, err)
+	}
+	for _, data := range [][]byte{{5, 'k'}, {1, 'k', 9}, {1, 0xff, 0}} {
+		if _, err = parseTags(data); err == nil {
+			t.Errorf("%v should be invalid", data)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// 扩展数据: silk v3 文件的 footer(长度为 -1 的数据块)之后可以追加扩展块, 参考解码器读到 footer 就结束, 会忽略这些数据.
+// 每个扩展块的格式: [4 字节 ID][4 字节小端序长度][内容]
+// STX 格式(微信/QQ)没有 footer, 不写入扩展块.
+
+const (
+	chunkHeaderLen = 8
+	chunkMaxLen    = 1 << 24 // 扩展块的最大长度, 超过时认为数据无效
+
+	metadataChunkID  = "META"
+	metadataChunkLen = 20
+)
+
+// chunk is an extension chunk after the footer.
+type chunk struct {
+	id   string
+	data []byte
+}
+
+// writeChunk writes an extension chunk.
+func writeChunk(w io.Writer, id string, data []byte) error {
+	var header = make([]byte, chunkHeaderLen)
+	copy(header, id)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write %s chunk: %w", id, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s chunk: %w", id, err)
+	}
+	return nil
+}
+
+// readChunks reads the extension chunks after the footer until EOF.
+// The chunks read before an invalid one are returned with the error.
+// 读取 footer 之后的所有扩展块, 遇到无效数据时返回之前读到的扩展块和错误
+func readChunks(r io.Reader) (chunks []chunk, err error) {
+	var header = make([]byte, chunkHeaderLen)
+	for {
+		if _, err = io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return chunks, nil
+			}
+			return chunks, fmt.Errorf("failed to read chunk header: %w", err)
+		}
+		var size = binary.LittleEndian.Uint32(header[4:])
+		if size > chunkMaxLen {
+			return chunks, fmt.Errorf("invalid chunk %q: size %d", header[:4], size)
+		}
+		var data = make([]byte, size)
+		if _, err = io.ReadFull(r, data); err != nil {
+			return chunks, fmt.Errorf("failed to read chunk %q: %w", header[:4], err)
+		}
+		chunks = append(chunks, chunk{id: string(header[:4]), data: data})
+	}
+}
+
+// Metadata is stored in the trailer after the footer of silk v3 file, see EncodeCfg.Metadata.
+// 保存在 silk v3 文件 footer 之后的元数据
+type Metadata struct {
+	Samples    int64     // number of samples of the original input, without the padding of the last frame
+	SampleRate int       // sample rate of the original input
+	Created    time.Time // creation time of the file
+}
+
+// Duration returns the duration of the original input.
+func (m *Metadata) Duration() time.Duration {
+	if m.SampleRate <= 0 {
+		return 0
+	}
+	return time.Duration(m.Samples * int64(time.Second) / int64(m.SampleRate))
+}
+
+func (m *Metadata) marshal() []byte {
+	var data = make([]byte, metadataChunkLen)
+	binary.LittleEndian.PutUint64(data, uint64(m.Samples))
+	binary.LittleEndian.PutUint32(data[8:], uint32(m.SampleRate))
+	binary.LittleEndian.PutUint64(data[12:], uint64(m.Created.UnixNano()))
+	return data
+}
+
+func (m *Metadata) unmarshal(data []byte) error {
+	if len(data) < metadataChunkLen { // 以后可能追加字段, 允许更长
+		return fmt.Errorf("invalid metadata chunk: %d bytes", len(data))
+	}
+	m.Samples = int64(binary.LittleEndian.Uint64(data))
+	m.SampleRate = int(binary.LittleEndian.Uint32(data[8:]))
+	m.Created = time.Unix(0, int64(binary.LittleEndian.Uint64(data[12:])))
+	return nil
+}
+
+// trailer is the extension chunks read after the footer.
+type trailer struct {
+	chunks []chunk
+	read   bool // footer 已读取
+}
+
+// parse reads the chunks after the footer, invalid data is ignored.
+func (t *trailer) parse(r io.Reader) {
+	t.read = true
+	chunks, err := readChunks(r)
+	if err != nil {
+		log("ignore invalid data after footer: %v", err)
+	}
+	t.chunks = chunks
+	log("chunks after footer: %d", len(chunks))
+}
+
+// metadata returns the metadata in the trailer, nil when not present.
+func (t *trailer) metadata() *Metadata {
+	for _, c := range t.chunks {
+		if c.id != metadataChunkID {
+			continue
+		}
+		var m Metadata
+		if err := m.unmarshal(c.data); err != nil {
+			log("%v", err)
+			return nil
+		}
+		return &m
+	}
+	return nil
+}
+
+// trailerReader is implemented by the packet readers that read the trailer after the footer.
+type trailerReader interface {
+	trailer() *trailer
+}
+
+// applyMetadata sets the original length from the metadata when it is not set, so the padding is trimmed.
+// 没有指定原始长度时, 使用元数据中的原始长度去掉末尾的填充
+func (cfg *DecodeCfg) applyMetadata(packets packetReader) {
+	if cfg.OriginalSamples > 0 {
+		return
+	}
+	r, ok := packets.(trailerReader)
+	if !ok {
+		return
+	}
+	if m := r.trailer().metadata(); m != nil {
+		log("metadata: %+v", m)
+		cfg.OriginalSamples, cfg.OriginalSampleRate = m.Samples, m.SampleRate
+	}
+}
+
+// ReadMetadata reads the metadata in the trailer of silk v3 file, nil is returned when there is no metadata.
+// 读取 silk v3 文件 footer 之后的元数据, 没有元数据时返回 nil
+func ReadMetadata(src io.Reader) (*Metadata, error) {
+	t, err := readTrailer(src)
+	if err != nil {
+		return nil, err
+	}
+	return t.metadata(), nil
+}
+
+// readTrailer skips the packets of silk v3 file, and reads the trailer.
+func readTrailer(src io.Reader) (*trailer, error) {
+	var reader = bufio.NewReader(src)
+	if err := checkHeader(reader); err != nil {
+		return nil, err
+	}
+	var r = &silkReader{reader: reader}
+	for {
+		if _, err := r.ReadPacket(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return &r.tail, nil
+			}
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMetadata(t *testing.T) {
+	var (
+		pcm   = sinePCM(16000, 1, 0.3)[:16000+333]
+		start = time.Now()
+	)
+	silk, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) {
+		ec.SampleRate = 16000
+		ec.PacketSizeMs = 40
+		ec.Metadata = true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadMetadata(bytes.NewReader(silk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.Samples != 8167 || m.SampleRate != 16000 || m.Created.Before(start.Truncate(time.Second)) {
+		t.Fatalf("unexpected metadata %+v", m)
+	}
+	// 解码时自动去掉填充, 采样率不同时按比例换算
+	for _, rate := range []int{16000, 24000, 48000} {
+		got, err := Decode(bytes.NewReader(silk), func(dc *DecodeCfg) { dc.SampleRate = rate })
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want = (8167*int64(rate) + 8000) / 16000 * 2
+		if int64(len(got)) != want {
+			t.Errorf("rate=%d: decoded %d bytes, want %d", rate, len(got), want)
+		}
+		d, err := NewDecoder(bytes.NewReader(silk), func(dc *DecodeCfg) { dc.SampleRate = rate })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err = io.ReadAll(d); err != nil || int64(len(got)) != want {
+			t.Errorf("rate=%d: Decoder read %d bytes, err=%v, want %d", rate, len(got), err, want)
+		}
+		d.Close()
+	}
+
+	// STX 格式没有 footer, 不写入元数据
+	stx, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) { ec.SampleRate = 16000; ec.Stx = true; ec.Metadata = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) { ec.SampleRate = 16000; ec.Stx = true })
+	if !bytes.Equal(stx, want) {
+		t.Errorf("metadata should not be written in stx format")
+	}
+	if m, err = ReadMetadata(bytes.NewReader(stx)); err != nil || m != nil {
+		t.Errorf("stx: metadata=%+v, err=%v", m, err)
+	}
+}
+
+func TestTrailerGarbage(t *testing.T) {
+	var pcm = sinePCM(defaultSampleRate, 1, 0.3)
+	silk, err := Encode(bytes.NewReader(pcm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Decode(bytes.NewReader(silk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// footer 之后的无效数据被忽略
+	got, err := Decode(bytes.NewReader(append(silk, "garbage after footer"...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("decoded %d bytes, want %d", len(got), len(want))
+	}
+}
@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// silenceTrimmer drops leading and trailing silent frames of 16bit pcm, and keeps padding frames around the sound.
+// A frame is silent when its RMS level is below the threshold(dBFS).
+// Silent frames in the middle are kept.
+// 去掉 pcm 开头和结尾的静音帧(RMS 电平低于阈值), 在声音前后保留 padding 帧, 中间的静音不受影响
+type silenceTrimmer struct {
+	reader    io.Reader
+	frameSize int     // 每帧的字节数
+	threshold float64 // 每个采样点的平均能量阈值
+	padding   int     // 保留的静音帧数
+	started   bool    // 是否已经遇到过非静音帧
+	pending   [][]byte
+	out       bytes.Buffer
+	eof       bool
+	dropped   int // for debug log
+}
+
+func newSilenceTrimmer(reader io.Reader, frameSize int, thresholdDb float64, padding int) *silenceTrimmer {
+	var amplitude = 32768 * math.Pow(10, thresholdDb/20)
+	return &silenceTrimmer{
+		reader:    reader,
+		frameSize: frameSize,
+		threshold: amplitude * amplitude,
+		padding:   padding,
+	}
+}
+
+func (r *silenceTrimmer) Read(p []byte) (int, error) {
+	for r.out.Len() == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	return r.out.Read(p)
+}
+
+// next reads a frame and decides whether to output it.
+func (r *silenceTrimmer) next() error {
+	var frame = make([]byte, r.frameSize)
+	n, err := io.ReadFull(r.reader, frame)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("failed to read pcm: %w", err)
+	}
+	if n < r.frameSize {
+		r.eof = true
+	}
+	if n > 0 {
+		r.push(frame[:n])
+	}
+	if r.eof {
+		// 结尾的静音只保留 padding 帧
+		if r.started && len(r.pending) > r.padding {
+			r.dropped += len(r.pending) - r.padding
+			r.pending = r.pending[:r.padding]
+		}
+		if !r.started {
+			r.dropped += len(r.pending)
+			r.pending = nil
+		}
+		r.flush()
+		log("trim silence: dropped %d frames", r.dropped)
+	}
+	return nil
+}
+
+func (r *silenceTrimmer) push(frame []byte) {
+	if !r.silent(frame) {
+		r.started = true
+		r.flush()
+		r.out.Write(frame)
+		return
+	}
+	r.pending = append(r.pending, frame)
+	if !r.started && len(r.pending) > r.padding {
+		// 开头的静音只保留最后 padding 帧
+		r.pending = r.pending[1:]
+		r.dropped++
+	}
+}
+
+func (r *silenceTrimmer) flush() {
+	for _, frame := range r.pending {
+		r.out.Write(frame)
+	}
+	r.pending = nil
+}
+
+func (r *silenceTrimmer) silent(frame []byte) bool {
+	var samples = len(frame) / 2
+	if samples == 0 {
+		return true
+	}
+	var energy float64
+	for i := 0; i < samples; i++ {
+		var s = float64(int16(binary.LittleEndian.Uint16(frame[i*2:])))
+		energy += s * s
+	}
+	return energy/float64(samples) < r.threshold
+}
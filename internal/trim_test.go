@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTrimSilence(t *testing.T) {
+	const frame = FRAME_LENGTH_MS * defaultSampleRate / 1000 // 每帧的采样数
+	var pcm []byte
+	appendFrames := func(n int, amplitude float64) {
+		for i := 0; i < n*frame; i++ {
+			var s = amplitude * math.Sin(2*math.Pi*440*float64(i)/defaultSampleRate)
+			pcm = binary.LittleEndian.AppendUint16(pcm, uint16(int16(s)))
+		}
+	}
+	appendFrames(50, 10) // 约 -70dB 的噪声
+	appendFrames(10, 8000)
+	appendFrames(5, 0)
+	appendFrames(10, 8000)
+	appendFrames(50, 10)
+
+	got, err := io.ReadAll(newSilenceTrimmer(bytes.NewReader(pcm), frame*2, -40, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 开头和结尾各保留 2 帧, 中间的静音保留
+	if want := pcm[48*frame*2 : 77*frame*2]; !bytes.Equal(got, want) {
+		t.Errorf("trimmed %d frames, want %d", len(got)/frame/2, len(want)/frame/2)
+	}
+
+	silk, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) {
+		ec.TrimSilence, ec.TrimThresholdDb, ec.TrimPadding = true, -40, 40*time.Millisecond
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(bytes.NewReader(silk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 29*frame*2 {
+		t.Errorf("decoded %d frames, want 29", len(decoded)/frame/2)
+	}
+
+	// 全部是静音时没有输出
+	if got, _ = io.ReadAll(newSilenceTrimmer(bytes.NewReader(make([]byte, 10*frame*2)), frame*2, -40, 2)); len(got) != 0 {
+		t.Errorf("expected empty output, got %d bytes", len(got))
+	}
+}
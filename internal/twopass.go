@@ -0,0 +1,83 @@
+package internal
+
+/*
+#include "SKP_Silk_SDK_API.h"
+#include "SKP_Silk_define.h"
+*/
+import "C"
+
+// 两遍编码: 第一遍试编码整个输入, 从每帧数据包的 TOC 中得到 VAD 标记和信号类型(浊音/清音);
+// 第二遍按帧的类型分配码率, 浊音分配更多, 清音和静音分配更少, 平均码率保持不变.
+
+// Bitrate weights of the frame types in two-pass mode.
+// 两遍编码中各类型帧的码率权重
+const (
+	voicedWeight   = 1.4 // 浊音(有基音的语音)
+	unvoicedWeight = 1.0 // 清音
+	inactiveWeight = 0.5 // 静音或背景噪声
+)
+
+// analyzeFrames trial encodes the pcm with a separate encoder, and returns the bitrate weight of each frame(20ms).
+// 用单独的编码器试编码, 返回每帧的码率权重
+func analyzeFrames(pcm []byte, cfg *EncodeCfg) []float64 {
+	var (
+		psEnc, free = malloc(getEncoderSize())
+		control     = buildEncControl(cfg)
+		frameSize   = FRAME_LENGTH_MS * cfg.SampleRate / 1000 * 2
+		payload     = make([]byte, MAX_BYTES_PER_FRAME*MAX_INPUT_FRAMES)
+		weights     = make([]float64, 0, (len(pcm)+frameSize-1)/frameSize)
+	)
+	defer free()
+	initEncode(psEnc)
+	// 每帧一个数据包, 不使用 DTX/FEC, 这样每帧都有自己的 TOC
+	control.packetSize = C.SKP_int(FRAME_LENGTH_MS * cfg.SampleRate / 1000)
+	control.useDTX, control.useInBandFEC = 0, 0
+	for i := 0; i < len(pcm); i += frameSize {
+		var frame = pcm[i:]
+		if len(frame) >= frameSize {
+			frame = frame[:frameSize]
+		} else { // 最后不足一帧时补零
+			frame = make([]byte, frameSize)
+			padFrame(frame, copy(frame, pcm[i:]), cfg.FadeOut)
+		}
+		var weight = inactiveWeight
+		packet, err := encodeFrame(psEnc, control, frame, payload)
+		if err != nil {
+			warn("%v", err)
+		} else if toc, ok := readTOC(packet); ok && toc.vadFlags[0] != 0 {
+			weight = unvoicedWeight
+			if toc.sigtypeFlags[0] == C.SIG_TYPE_VOICED {
+				weight = voicedWeight
+			}
+		}
+		weights = append(weights, weight)
+	}
+	log("two-pass analysis: %d frames", len(weights))
+	return weights
+}
+
+// setWeights allocates the budget of bitRate over the frames by weights.
+func (rc *rateControl) setWeights(weights []float64, bitRate int) {
+	rc.weights = weights
+	rc.rest = make([]float64, len(weights)+1)
+	for i := len(weights) - 1; i >= 0; i-- {
+		rc.rest[i] = rc.rest[i+1] + weights[i]
+	}
+	if bitRate < C.MIN_TARGET_RATE_BPS { // BitRate 为 0 时 SDK 也会限制到最小码率
+		bitRate = C.MIN_TARGET_RATE_BPS
+	}
+	if rc.budget == 0 {
+		rc.budget = int64(bitRate) * int64(len(weights)) * FRAME_LENGTH_MS / 1000 / 8
+		rc.frames = len(weights)
+	}
+	rc.adjust()
+}
+
+// share returns the share of the remaining budget for the next frame.
+func (rc *rateControl) share() float64 {
+	var next = rc.stats.Frames
+	if rc.weights == nil || next >= len(rc.weights) || rc.rest[next] == 0 {
+		return 1 / float64(rc.frames-next)
+	}
+	return rc.weights[next] / rc.rest[next]
+}
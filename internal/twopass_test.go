@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestTwoPass(t *testing.T) {
+	pcm, err := os.ReadFile("../cmd/testdata/hao.decode.pcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var weights = analyzeFrames(pcm, buildCfg())
+	if len(weights) != len(pcm)/960 {
+		t.Fatalf("got %d weights, want %d", len(weights), len(pcm)/960)
+	}
+	// 浊音帧平均大小: 两遍编码应该比一遍编码更大, 总大小接近目标码率
+	var voiced = func(twoPass bool) (avg float64, stats EncodeStats) {
+		var lengths []int
+		if _, err := Encode(bytes.NewReader(pcm), func(ec *EncodeCfg) {
+			ec.TwoPass = twoPass
+			ec.PacketLengths = &lengths
+			ec.Stats = &stats
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var sum, count int
+		for i, n := range lengths {
+			if weights[i] == voicedWeight {
+				sum += n
+				count++
+			}
+		}
+		if count == 0 {
+			t.Fatal("no voiced frames")
+		}
+		return float64(sum) / float64(count), stats
+	}
+	one, _ := voiced(false)
+	two, stats := voiced(true)
+	if two <= one {
+		t.Errorf("voiced frames: two-pass %.1f bytes, one-pass %.1f bytes", two, one)
+	}
+	var bitRate = float64(stats.Payload*8) / stats.Duration.Seconds()
+	if bitRate > 25000*1.05 {
+		t.Errorf("average bitrate %.0f exceeds 25000", bitRate)
+	}
+}
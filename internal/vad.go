@@ -0,0 +1,158 @@
+package internal
+
+/*
+#include "SKP_Silk_main.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unsafe"
+)
+
+const (
+	// speechThreshold is the speech activity level above which a frame is speech,
+	// the same as SPEECH_ACTIVITY_DTX_THRES used by the encoder.
+	// 语音活动概率超过该值时认为是语音帧, 与编码器判断 VAD 标记的阈值相同
+	speechThreshold = 0.1
+	// speechHangover merges speech segments separated by pauses shorter than it.
+	// 间隔小于该值的语音段会被合并
+	speechHangover = 200 * time.Millisecond
+)
+
+// vadSampleRates are the sample rates supported by SKP_Silk_VAD_GetSA_Q8, the internal sample rates of the encoder.
+var vadSampleRates = []int{24000, 16000, 12000, 8000}
+
+// SpeechSegment is a time range that contains speech.
+// 包含语音的时间段
+type SpeechSegment struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// SpeechResult is the result of DetectSpeech.
+// 语音检测结果
+type SpeechResult struct {
+	FrameDuration time.Duration   // duration of each frame in Probability, 20ms
+	Probability   []float64       // speech probability(0-1) of each frame
+	Segments      []SpeechSegment // speech segments, pauses shorter than 200ms are merged
+}
+
+// DetectSpeech runs the voice activity detector of the encoder(SKP_Silk_VAD) over 16bit little endian pcm.
+// 对 16 位小端序的 pcm 数据进行语音活动检测(使用编码器内部的 VAD)
+func DetectSpeech(pcm io.Reader, sampleRate int) (*SpeechResult, error) {
+	if sampleRate > MAX_API_FS_KHZ*1000 || sampleRate < 8000 {
+		return nil, fmt.Errorf("error: sampling rate = %d out of range, valid range 8000 - 48000", sampleRate)
+	}
+	// VAD 只支持内部采样率, 其他采样率需要先重采样
+	var vadRate int
+	for _, rate := range vadSampleRates {
+		if rate <= sampleRate {
+			vadRate = rate
+			break
+		}
+	}
+	var (
+		state     C.SKP_Silk_VAD_state
+		resampler C.SKP_Silk_resampler_state_struct
+		frameSize = FRAME_LENGTH_MS * sampleRate / 1000
+		vadSize   = FRAME_LENGTH_MS * vadRate / 1000
+		in        = make([]byte, frameSize*2)
+		frame     = make([]byte, vadSize*2) // 重采样后的一帧
+		result    = &SpeechResult{FrameDuration: FRAME_LENGTH_MS * time.Millisecond}
+	)
+	// SKP_int SKP_Silk_VAD_Init(
+	//     SKP_Silk_VAD_state          *psSilk_VAD         /* I/O  Pointer to Silk VAD state                   */
+	// );
+	if ret := C.SKP_Silk_VAD_Init(&state); ret != 0 {
+		return nil, fmt.Errorf("failed to init VAD, ret=%d", ret)
+	}
+	if vadRate != sampleRate {
+		// SKP_int SKP_Silk_resampler_init(
+		//     SKP_Silk_resampler_state_struct *S,         /* I/O: Resampler state             */
+		//     SKP_int32                       Fs_Hz_in,   /* I:   Input sampling rate (Hz)    */
+		//     SKP_int32                       Fs_Hz_out   /* I:   Output sampling rate (Hz)   */
+		// );
+		if ret := C.SKP_Silk_resampler_init(&resampler, C.SKP_int32(sampleRate), C.SKP_int32(vadRate)); ret != 0 {
+			return nil, fmt.Errorf("failed to init resampler, ret=%d", ret)
+		}
+	}
+	log("vad sampleRate=%d, vadRate=%d", sampleRate, vadRate)
+
+	for {
+		n, err := io.ReadFull(pcm, in)
+		if n == 0 {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to read pcm: %w", err)
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("failed to read pcm: %w", err)
+		}
+		for i := n; i < len(in); i++ { // 最后不足一帧时补零
+			in[i] = 0
+		}
+		var samples = in
+		if vadRate != sampleRate {
+			// SKP_int SKP_Silk_resampler(
+			//     SKP_Silk_resampler_state_struct *S,     /* I/O: Resampler state             */
+			//     SKP_int16                       out[],  /* O:   Output signal               */
+			//     const SKP_int16                 in[],   /* I:   Input signal                */
+			//     SKP_int32                       inLen   /* I:   Number of input samples     */
+			// );
+			C.SKP_Silk_resampler(
+				&resampler,
+				(*C.SKP_int16)(unsafe.Pointer(&frame[0])),
+				(*C.SKP_int16)(unsafe.Pointer(&in[0])),
+				C.SKP_int32(frameSize),
+			)
+			samples = frame
+		}
+
+		var (
+			saQ8, snrQ7, tiltQ15 C.SKP_int
+			quality              [C.VAD_N_BANDS]C.SKP_int
+		)
+		// SKP_int SKP_Silk_VAD_GetSA_Q8(
+		//     SKP_Silk_VAD_state          *psSilk_VAD,                    /* I/O  Silk VAD state                  */
+		//     SKP_int                     *pSA_Q8,                        /* O    Speech activity level in Q8     */
+		//     SKP_int                     *pSNR_dB_Q7,                    /* O    SNR for current frame in Q7     */
+		//     SKP_int                     pQuality_Q15[ VAD_N_BANDS ],    /* O    Smoothed SNR for each band      */
+		//     SKP_int                     *pTilt_Q15,                     /* O    current frame's frequency tilt  */
+		//     const SKP_int16             pIn[],                          /* I    PCM input       [framelength]   */
+		//     const SKP_int               framelength                     /* I    Input frame length              */
+		// );
+		C.SKP_Silk_VAD_GetSA_Q8(
+			&state, &saQ8, &snrQ7, &quality[0], &tiltQ15,
+			(*C.SKP_int16)(unsafe.Pointer(&samples[0])),
+			C.SKP_int(vadSize),
+		)
+		result.Probability = append(result.Probability, float64(saQ8)/256)
+		if n < len(in) {
+			break
+		}
+	}
+	result.Segments = speechSegments(result.Probability, result.FrameDuration)
+	log("vad frames=%d, segments=%d", len(result.Probability), len(result.Segments))
+	return result, nil
+}
+
+// speechSegments merges speech frames into segments.
+func speechSegments(probability []float64, frame time.Duration) (segments []SpeechSegment) {
+	for i, p := range probability {
+		if p < speechThreshold {
+			continue
+		}
+		var start, end = time.Duration(i) * frame, time.Duration(i+1) * frame
+		if last := len(segments) - 1; last >= 0 && start-segments[last].End < speechHangover {
+			segments[last].End = end
+			continue
+		}
+		segments = append(segments, SpeechSegment{Start: start, End: end})
+	}
+	return segments
+}
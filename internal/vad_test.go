@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDetectSpeech(t *testing.T) {
+	pcm, err := os.ReadFile("../cmd/testdata/hao.decode.pcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 前后各加 1 秒静音
+	var silence = make([]byte, defaultSampleRate*2)
+	var input = append(append(append([]byte(nil), silence...), pcm...), silence...)
+	result, err := DetectSpeech(bytes.NewReader(input), defaultSampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (len(input) + 959) / 960; len(result.Probability) != want {
+		t.Errorf("got %d frames, want %d", len(result.Probability), want)
+	}
+	if len(result.Segments) == 0 {
+		t.Fatalf("no speech detected")
+	}
+	var first, last = result.Segments[0], result.Segments[len(result.Segments)-1]
+	// VAD 有平滑处理, 结束时间允许稍有延迟
+	if first.Start < time.Second || last.End > time.Second+time.Duration(len(pcm)/48+100)*time.Millisecond {
+		t.Errorf("speech should not be detected in silence: %+v", result.Segments)
+	}
+
+	// 重采样: 48kHz 输入与 24kHz 输入的检测结果应该接近
+	var up = make([]byte, 0, len(input)*2)
+	for i := 0; i+1 < len(input); i += 2 {
+		var s = binary.LittleEndian.Uint16(input[i:])
+		up = binary.LittleEndian.AppendUint16(binary.LittleEndian.AppendUint16(up, s), s)
+	}
+	resampled, err := DetectSpeech(bytes.NewReader(up), 48000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resampled.Probability) != len(result.Probability) || len(resampled.Segments) == 0 {
+		t.Errorf("unexpected result of 48kHz input: frames=%d, segments=%+v", len(resampled.Probability), resampled.Segments)
+	}
+
+	if _, err = DetectSpeech(bytes.NewReader(pcm), 4000); err == nil {
+		t.Errorf("expected error of invalid sample rate")
+	}
+}
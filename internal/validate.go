@@ -0,0 +1,149 @@
+package internal
+
+/*
+#include "SKP_Silk_define.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// 参数检查: 编码/解码前检查所有参数, 一次返回所有错误, 避免无效参数传给 SDK 后每帧都失败.
+
+var (
+	apiSampleRates      = []int{8000, 12000, 16000, 24000, 32000, 44100, 48000}
+	internalSampleRates = []int{8000, 12000, 16000, 24000}
+	packetSizes         = []int{20, 40, 60, 80, 100}
+)
+
+// Validate checks all fields of the encode config, and returns an error listing every invalid field.
+// 检查编码参数, 返回的错误中包含所有无效的参数
+func (cfg *EncodeCfg) Validate() error {
+	var errs []error
+	if !contains(apiSampleRates, cfg.SampleRate) {
+		errs = append(errs, fmt.Errorf("invalid SampleRate %d, should be one of %v", cfg.SampleRate, apiSampleRates))
+	}
+	if !contains(internalSampleRates, cfg.MaxInternalSampleRate) {
+		errs = append(errs, fmt.Errorf("invalid MaxInternalSampleRate %d, should be one of %v", cfg.MaxInternalSampleRate, internalSampleRates))
+	}
+	if !contains(packetSizes, cfg.PacketSizeMs) {
+		errs = append(errs, fmt.Errorf("invalid PacketSizeMs %d, should be one of %v", cfg.PacketSizeMs, packetSizes))
+	}
+	if cfg.PacketLossPct < 0 || cfg.PacketLossPct > 100 {
+		errs = append(errs, fmt.Errorf("invalid PacketLossPct %d, valid range 0 - 100", cfg.PacketLossPct))
+	}
+	if cfg.ComplexityMode < 0 || cfg.ComplexityMode > 2 {
+		errs = append(errs, fmt.Errorf("invalid ComplexityMode %d, valid range 0 - 2", cfg.ComplexityMode))
+	}
+	if cfg.BitRate != 0 && (cfg.BitRate < C.MIN_TARGET_RATE_BPS || cfg.BitRate > C.MAX_TARGET_RATE_BPS) {
+		errs = append(errs, fmt.Errorf("invalid BitRate %d, valid range %d - %d, or 0 means the SDK default",
+			cfg.BitRate, C.MIN_TARGET_RATE_BPS, C.MAX_TARGET_RATE_BPS))
+	}
+	if _, err := newLengthPrefix(cfg.LengthSize, cfg.LengthBigEndian); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.Channels < 0 {
+		errs = append(errs, fmt.Errorf("invalid Channels %d", cfg.Channels))
+	}
+	if cfg.Channel < 0 || (cfg.Channel > 0 && cfg.Channel > cfg.Channels) {
+		errs = append(errs, fmt.Errorf("invalid Channel %d, the input has %d channels", cfg.Channel, cfg.Channels))
+	}
+	if cfg.TrimSilence && !(cfg.TrimThresholdDb <= 0) { // 同时排除 NaN
+		errs = append(errs, fmt.Errorf("invalid TrimThresholdDb %v, should be at most 0 dBFS", cfg.TrimThresholdDb))
+	}
+	if cfg.TrimSilence && cfg.TrimPadding < 0 {
+		errs = append(errs, fmt.Errorf("invalid TrimPadding %v", cfg.TrimPadding))
+	}
+	if cfg.Normalize != nil {
+		errs = append(errs, cfg.Normalize.validate()...)
+	}
+	if cfg.TargetSize < 0 {
+		errs = append(errs, fmt.Errorf("invalid TargetSize %d", cfg.TargetSize))
+	}
+	if cfg.CBRPacketSize < 0 || cfg.CBRPacketSize > MAX_BYTES_PER_FRAME*MAX_INPUT_FRAMES ||
+		(cfg.CBRPacketSize > 0 && cfg.CBRPacketSize < minCBRPacketSize(cfg.PacketSizeMs)) {
+		errs = append(errs, fmt.Errorf("invalid CBRPacketSize %d, valid range %d - %d for %dms packets",
+			cfg.CBRPacketSize, minCBRPacketSize(cfg.PacketSizeMs), MAX_BYTES_PER_FRAME*MAX_INPUT_FRAMES, cfg.PacketSizeMs))
+	}
+	if cfg.TargetSize > 0 && cfg.CBRPacketSize > 0 {
+		errs = append(errs, errors.New("TargetSize and CBRPacketSize can not be used together"))
+	}
+	if cfg.TwoPass && cfg.CBRPacketSize > 0 {
+		errs = append(errs, errors.New("TwoPass and CBRPacketSize can not be used together"))
+	}
+	if len(cfg.Tags) > 0 {
+		if err := cfg.Tags.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid Tags: %w", err))
+		}
+		// 标签写在 footer 之后, 没有 footer 时不能静默丢弃
+		if cfg.Stx || cfg.NoHeader || cfg.Ogg || cfg.PacketLengths != nil || cfg.LengthSize == 1 {
+			errs = append(errs, errors.New("Tags need an output with footer, can not be used with Stx, NoHeader, Ogg, PacketLengths or 1-byte length prefix"))
+		}
+	}
+	if err := cfg.checkPreset(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks all fields of the decode config, and returns an error listing every invalid field.
+// 检查解码参数, 返回的错误中包含所有无效的参数
+func (cfg *DecodeCfg) Validate() error {
+	var errs []error
+	if cfg.SampleRate < 8000 || cfg.SampleRate > MAX_API_FS_KHZ*1000 {
+		errs = append(errs, fmt.Errorf("invalid SampleRate %d, valid range 8000 - %d", cfg.SampleRate, MAX_API_FS_KHZ*1000))
+	}
+	if cfg.PayloadType < -1 || cfg.PayloadType > 127 {
+		errs = append(errs, fmt.Errorf("invalid PayloadType %d, valid range 0 - 127, or -1 means any", cfg.PayloadType))
+	}
+	if _, err := newLengthPrefix(cfg.LengthSize, cfg.LengthBigEndian); err != nil {
+		errs = append(errs, err)
+	}
+	for i, n := range cfg.PacketLengths {
+		if n < 0 {
+			errs = append(errs, fmt.Errorf("invalid PacketLengths[%d] %d", i, n))
+			break
+		}
+	}
+	if cfg.Channels < 0 {
+		errs = append(errs, fmt.Errorf("invalid Channels %d", cfg.Channels))
+	}
+	if cfg.Normalize != nil {
+		errs = append(errs, cfg.Normalize.validate()...)
+		if cfg.Gain != 0 {
+			errs = append(errs, fmt.Errorf("invalid Gain %v, can not be used with Normalize", cfg.Gain))
+		}
+	}
+	if math.IsNaN(cfg.Gain) || math.IsInf(cfg.Gain, 0) {
+		errs = append(errs, fmt.Errorf("invalid Gain %v", cfg.Gain))
+	}
+	if cfg.OriginalSamples < 0 {
+		errs = append(errs, fmt.Errorf("invalid OriginalSamples %d", cfg.OriginalSamples))
+	}
+	if cfg.OriginalSampleRate < 0 || cfg.OriginalSampleRate > MAX_API_FS_KHZ*1000 {
+		errs = append(errs, fmt.Errorf("invalid OriginalSampleRate %d", cfg.OriginalSampleRate))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *NormalizeCfg) validate() (errs []error) {
+	if c.Mode < NormalizeLUFS || c.Mode > NormalizePeak {
+		errs = append(errs, fmt.Errorf("invalid normalize Mode %d", c.Mode))
+	}
+	if c.TruePeak > 0 {
+		errs = append(errs, fmt.Errorf("invalid normalize TruePeak %v, should not be positive", c.TruePeak))
+	}
+	return errs
+}
+
+func contains(list []int, v int) bool {
+	for _, i := range list {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeCfgValidate(t *testing.T) {
+	if err := buildCfg().Validate(); err != nil {
+		t.Errorf("default config should be valid: %v", err)
+	}
+	var cfg = buildCfg(func(ec *EncodeCfg) {
+		ec.SampleRate = 22050
+		ec.MaxInternalSampleRate = 20000
+		ec.PacketSizeMs = 30
+		ec.PacketLossPct = 101
+		ec.ComplexityMode = 3
+		ec.BitRate = 1000000
+		ec.LengthSize = 3
+		ec.Channel = 2
+		ec.TrimSilence, ec.TrimThresholdDb = true, 10
+		ec.Normalize = &NormalizeCfg{Mode: 9, TruePeak: 1}
+		ec.TargetSize = -1
+		ec.CBRPacketSize = 10
+	})
+	var err = cfg.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	// 每个无效的参数都在错误中
+	for _, field := range []string{"SampleRate", "MaxInternalSampleRate", "PacketSizeMs", "PacketLossPct",
+		"ComplexityMode", "BitRate", "length prefix", "Channel", "TrimThresholdDb", "Mode", "TruePeak", "TargetSize", "CBRPacketSize"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error should contain %s: %v", field, err)
+		}
+	}
+	// BitRate 为 0 表示不设置, 由 SDK 决定
+	if err = buildCfg(func(ec *EncodeCfg) { ec.BitRate = 0 }).Validate(); err != nil {
+		t.Errorf("BitRate 0 should be valid: %v", err)
+	}
+	if err = buildCfg(func(ec *EncodeCfg) { ec.BitRate = -1 }).Validate(); err == nil {
+		t.Errorf("expected error of negative BitRate")
+	}
+	if _, err = Encode(bytes.NewReader(make([]byte, 960)), func(ec *EncodeCfg) { ec.PacketSizeMs = 30 }); err == nil {
+		t.Errorf("Encode should validate options")
+	}
+}
+
+func TestDecodeCfgValidate(t *testing.T) {
+	if err := buildDecodeCfg().Validate(); err != nil {
+		t.Errorf("default config should be valid: %v", err)
+	}
+	for _, rate := range []int{8000, 11025, 44100, 48000} {
+		if err := buildDecodeCfg(func(dc *DecodeCfg) { dc.SampleRate = rate }).Validate(); err != nil {
+			t.Errorf("sample rate %d should be valid: %v", rate, err)
+		}
+	}
+	var err = buildDecodeCfg(func(dc *DecodeCfg) {
+		dc.SampleRate = 96000
+		dc.PayloadType = 128
+		dc.PacketLengths = []int{1, -1}
+	}).Validate()
+	for _, field := range []string{"SampleRate", "PayloadType", "PacketLengths[1]"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("error should contain %s: %v", field, err)
+		}
+	}
+	if _, err = Decode(bytes.NewReader(nil), func(dc *DecodeCfg) { dc.SampleRate = 4000 }); err == nil ||
+		!strings.Contains(err.Error(), "SampleRate") {
+		t.Errorf("Decode should validate options: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package silk
+
+import (
+	"io"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// NormalizeMode is the loudness measurement used by normalization.
+// 响度标准化的测量方式
+type NormalizeMode = internal.NormalizeMode
+
+const (
+	// NormalizeLUFS measures EBU R128 integrated loudness, the target is in LUFS(e.g. -16).
+	// 按 EBU R128 积分响度测量, 目标单位为 LUFS
+	NormalizeLUFS = internal.NormalizeLUFS
+	// NormalizeRMS measures RMS level, the target is in dBFS.
+	// 按 RMS 电平测量, 目标单位为 dBFS
+	NormalizeRMS = internal.NormalizeRMS
+	// NormalizePeak measures sample peak, the target is in dBFS.
+	// 按采样峰值测量, 目标单位为 dBFS
+	NormalizePeak = internal.NormalizePeak
+)
+
+// NormalizeCfg is the setting of loudness normalization, TruePeak is the ceiling in dBTP(0 means -1 dBTP).
+// 响度标准化设置, TruePeak 是真峰值上限(0 表示 -1 dBTP)
+type NormalizeCfg = internal.NormalizeCfg
+
+// -------------------- Decode --------------------
+
+// WithNormalize set decode option, normalize the loudness of output pcm to target.
+// Decode measures the whole output first and applies a constant gain limited by the -1 dBTP true peak ceiling;
+// Decoder applies automatic gain control since the whole signal is not available,
+// use MeasureNormalizeGain and WithGain for two-pass streaming when the source can be read twice.
+// 设置解码后的响度标准化: Decode 先测量整段响度再统一调整增益(真峰值不超过 -1 dBTP), Decoder 使用自动增益控制;
+// 源数据可以读取两遍时, 可以使用 MeasureNormalizeGain 和 WithGain 两遍流式处理
+func WithNormalize(mode NormalizeMode, target float64) internal.DecodeOpt {
+	return func(dc *internal.DecodeCfg) {
+		dc.Normalize = &internal.NormalizeCfg{Mode: mode, Target: target}
+	}
+}
+
+// WithGain set decode option, apply a constant gain(dB) to output pcm, it can not be used with WithNormalize.
+// 设置解码后的固定增益(dB), 不能与 WithNormalize 同时使用
+func WithGain(db float64) internal.DecodeOpt {
+	return func(dc *internal.DecodeCfg) {
+		dc.Gain = db
+	}
+}
+
+// MeasureNormalizeGain decodes src with the options(including WithNormalize) and measures the loudness of the whole output,
+// returns the gain(dB) Decode would apply, decode the source again with WithGain(gain) to get the same output block by block.
+// 两遍处理的第一遍: 解码并测量整段响度, 返回 Decode 会使用的增益; 第二遍使用 WithGain(gain) 流式解码, 结果与 Decode 相同
+//
+//	gain, err := silk.MeasureNormalizeGain(f, silk.WithNormalize(silk.NormalizeLUFS, -16))
+//	f.Seek(0, io.SeekStart)
+//	d, err := silk.NewDecoder(f, silk.WithGain(gain))
+func MeasureNormalizeGain(src io.Reader, opts ...internal.DecodeOpt) (float64, error) {
+	return internal.MeasureNormalizeGain(src, opts...)
+}
+
+// -------------------- Encode --------------------
+
+// Normalize normalizes the loudness of input pcm to target before encoding,
+// the whole input is read and measured first, the gain is limited by the -1 dBTP true peak ceiling.
+// 编码前将输入的响度调整到 target: 先读取并测量全部输入, 增益受 -1 dBTP 真峰值上限限制
+func Normalize(mode NormalizeMode, target float64) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) {
+		ec.Normalize = &internal.NormalizeCfg{Mode: mode, Target: target}
+	}
+}
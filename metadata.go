@@ -0,0 +1,25 @@
+package silk
+
+import (
+	"io"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// Metadata is stored after the footer of silk v3 file: the original length, sample rate and creation time.
+// Other decoders stop at the footer and ignore it.
+// 保存在 silk v3 文件 footer 之后的元数据: 原始长度、采样率、创建时间; 其他解码器读到 footer 就结束, 不受影响
+type Metadata = internal.Metadata
+
+// WriteMetadata writes the metadata after the footer, Decode trims the padding of the last frame by it,
+// so the output has exactly the samples of the input. It is ignored when there is no footer(Stx is set).
+// 在 footer 之后写入元数据, 解码时据此去掉最后一帧的填充, 输出与输入的采样点数完全相同; 没有 footer 时(Stx)忽略
+func WriteMetadata(enable bool) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.Metadata = enable }
+}
+
+// ReadMetadata reads the metadata of silk v3 file, nil is returned when there is no metadata.
+// 读取 silk v3 文件的元数据, 没有元数据时返回 nil
+func ReadMetadata(src io.Reader) (*Metadata, error) {
+	return internal.ReadMetadata(src)
+}
@@ -0,0 +1,74 @@
+package silk
+
+import (
+	"io"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// MP3Supported reports whether mp3 encoding is available, it needs libmp3lame and the lame build tag.
+// 是否支持 mp3 编码, 需要 libmp3lame 并使用 -tags lame 编译
+const MP3Supported = internal.MP3Supported
+
+// ErrMP3Unsupported is returned by mp3 functions when built without lame tag.
+// 没有使用 -tags lame 编译时, mp3 相关函数返回此错误
+var ErrMP3Unsupported = internal.ErrMP3Unsupported
+
+// ID3Tags is the ID3v2 title/artist/comment written at the beginning of mp3.
+// 写在 mp3 开头的 ID3v2 标签(标题、艺术家、注释)
+type ID3Tags = internal.ID3Tags
+
+// NewMP3Writer returns a writer which encodes 16bit little endian pcm to mp3 and writes to w, Close flushes the encoder.
+// 创建 mp3 编码器, 写入 16 位小端序 pcm; Close 时输出剩余数据, 不会关闭 w
+func NewMP3Writer(w io.Writer, opts ...internal.MP3Opt) (io.WriteCloser, error) {
+	return internal.NewMP3Writer(w, opts...)
+}
+
+// DecodeToMP3 decodes silk from src and writes mp3 to w, block by block.
+// The decode settings are set by WithDecodeOpts, the input of mp3 encoder follows them.
+// 将 silk 解码并编码为 mp3 写入 w, 解码参数由 WithDecodeOpts 设置
+func DecodeToMP3(src io.Reader, w io.Writer, opts ...internal.MP3Opt) error {
+	return internal.DecodeToMP3(src, w, opts...)
+}
+
+// MP3Input set sample rate and channels of the pcm written to NewMP3Writer, default 24000Hz mono
+// 设置写入 NewMP3Writer 的 pcm 的采样率和声道数, 默认 24000Hz 单声道
+func MP3Input(sampleRate, channels int) internal.MP3Opt {
+	return func(mc *internal.MP3Cfg) { mc.SampleRate, mc.Channels = sampleRate, channels }
+}
+
+// MP3Bitrate set CBR bitrate in kbps, default 0(LAME default, 128), ignored in VBR mode
+// 设置固定比特率(kbps), 默认 0(LAME 默认值 128), VBR 模式下无效
+func MP3Bitrate(kbps int) internal.MP3Opt {
+	return func(mc *internal.MP3Cfg) { mc.Bitrate = kbps }
+}
+
+// MP3VBR set VBR quality 0(best) - 9(smallest), default -1(CBR)
+// 设置可变比特率的质量 0(最好) - 9(最小), 默认 -1(固定比特率)
+func MP3VBR(quality int) internal.MP3Opt {
+	return func(mc *internal.MP3Cfg) { mc.VBR = quality }
+}
+
+// MP3Quality set algorithm quality 0(best, slowest) - 9(fastest), default -1(LAME default)
+// 设置编码算法质量 0(最好, 最慢) - 9(最快), 默认 -1(LAME 默认值)
+func MP3Quality(quality int) internal.MP3Opt {
+	return func(mc *internal.MP3Cfg) { mc.Quality = quality }
+}
+
+// MP3OutSampleRate set sample rate of mp3, default 0(chosen by LAME)
+// 设置 mp3 的采样率, 默认 0(由 LAME 决定)
+func MP3OutSampleRate(sampleRate int) internal.MP3Opt {
+	return func(mc *internal.MP3Cfg) { mc.OutSampleRate = sampleRate }
+}
+
+// MP3ID3 set ID3v2 tags, empty fields are not written
+// 设置 ID3v2 标签, 为空的字段不写入
+func MP3ID3(tags ID3Tags) internal.MP3Opt {
+	return func(mc *internal.MP3Cfg) { mc.ID3 = &tags }
+}
+
+// WithDecodeOpts set decode options used by DecodeToMP3.
+// 设置 DecodeToMP3 的解码选项
+func WithDecodeOpts(opts ...internal.DecodeOpt) internal.MP3Opt {
+	return func(mc *internal.MP3Cfg) { mc.Decode = append(mc.Decode, opts...) }
+}
@@ -0,0 +1,30 @@
+package silk
+
+import (
+	"io"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// RTPStream describes a RTP stream found in a pcap/pcapng capture file.
+// 抓包文件中的一路 RTP 流
+type RTPStream = internal.RTPStream
+
+// PcapStreams lists RTP streams in a pcap/pcapng capture file.
+// 列出抓包文件中的 RTP 流
+func PcapStreams(src io.Reader) ([]RTPStream, error) {
+	return internal.PcapStreams(src)
+}
+
+// DecodePcap decodes the silk RTP stream identified by ssrc in a pcap/pcapng capture file to pcm.
+// Packets are ordered by sequence number, and missing packets are treated as lost.
+// 解码抓包文件中指定 SSRC 的 silk RTP 流为 pcm 格式, 按序号排序，缺失的包按丢包处理.
+func DecodePcap(src io.Reader, ssrc uint32, opts ...internal.DecodeOpt) ([]byte, error) {
+	return internal.DecodePcap(src, ssrc, opts...)
+}
+
+// WithPayloadType set decode option, only decode RTP packets with the payload type, default any
+// 设置 RTP 负载类型，默认不限制
+func WithPayloadType(pt int) internal.DecodeOpt {
+	return func(dc *internal.DecodeCfg) { dc.PayloadType = pt }
+}
@@ -0,0 +1,51 @@
+package silk
+
+import "github.com/youthlin/silk/internal"
+
+// Presets are bundles of encode options for common targets. Options after a preset can override its settings,
+// but Encode returns an error when the final options conflict with the requirements of the target.
+// 编码预设: 针对常见目标的一组编码参数, 之后的选项可以覆盖预设的参数, 但如果最终参数不满足目标的要求, Encode 会返回错误.
+
+// PresetWeChat encodes for WeChat voice messages: STX flag without footer, 24kHz internal sample rate, 20ms packets.
+// 微信语音: 有 STX 前缀, 无 footer, 内部采样率 24kHz, 20ms 数据包
+func PresetWeChat() internal.EncodeOpt {
+	return mustPreset("wechat")
+}
+
+// PresetQQ encodes for QQ voice messages: STX flag without footer, 24kHz internal sample rate, 20ms packets.
+// QQ 语音: 有 STX 前缀, 无 footer, 内部采样率 24kHz, 20ms 数据包
+func PresetQQ() internal.EncodeOpt {
+	return mustPreset("qq")
+}
+
+// PresetVoIPNarrowband encodes for narrowband VoIP: 8kHz internal sample rate, 12kbps, inband FEC and DTX enabled.
+// 窄带网络电话: 内部采样率 8kHz, 12kbps, 开启带内 FEC 和 DTX
+func PresetVoIPNarrowband() internal.EncodeOpt {
+	return mustPreset("voip-narrowband")
+}
+
+// PresetHighQuality encodes for archiving: 24kHz internal sample rate, 40kbps, highest complexity, with footer.
+// 高音质: 内部采样率 24kHz, 40kbps, 最高复杂度, 有 footer
+func PresetHighQuality() internal.EncodeOpt {
+	return mustPreset("high-quality")
+}
+
+// Preset returns the preset by name: wechat, qq, voip-narrowband, high-quality.
+// 根据名称返回预设
+func Preset(name string) (internal.EncodeOpt, bool) {
+	return internal.Preset(name)
+}
+
+// PresetNames returns the names of all presets.
+// 返回所有预设的名称
+func PresetNames() []string {
+	return internal.PresetNames()
+}
+
+func mustPreset(name string) internal.EncodeOpt {
+	opt, ok := internal.Preset(name)
+	if !ok {
+		panic("unknown preset: " + name)
+	}
+	return opt
+}
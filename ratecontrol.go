@@ -0,0 +1,33 @@
+package silk
+
+import "github.com/youthlin/silk/internal"
+
+// EncodeStats is the statistics of an encoding, see Stats.
+// 编码统计信息
+type EncodeStats = internal.EncodeStats
+
+// TargetSize adjusts the bitrate per frame to keep the output within size bytes,
+// e.g. the upload limit of a chat platform. An error is returned when the size is too small for the input.
+// 逐帧调整码率, 使输出文件不超过指定的字节数
+func TargetSize(size int64) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.TargetSize = size }
+}
+
+// CBR pads every packet to size bytes, and constrains the bitrate so that packets rarely exceed it.
+// 固定码率: 每个数据包补零到指定的字节数, 并限制码率使数据包尽量不超过该大小
+func CBR(size int) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.CBRPacketSize = size }
+}
+
+// Stats stores the statistics of the encoding to stats after encoding, including the achieved size.
+// 编码完成后将统计信息(包括实际输出大小)保存到 stats
+func Stats(stats *EncodeStats) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.Stats = stats }
+}
+
+// TwoPass analyzes the whole input with a trial encode first(VAD and signal type of each frame),
+// then allocates more bitrate to voiced frames and less to unvoiced/silent frames, keeping the average BitRate.
+// 两遍编码: 先试编码分析每帧的类型, 再给浊音帧分配更多码率, 清音和静音帧分配更少, 平均码率不变
+func TwoPass(enable bool) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.TwoPass = enable }
+}
@@ -0,0 +1,53 @@
+package silk
+
+import "github.com/youthlin/silk/internal"
+
+// -------------------- Decode --------------------
+
+// WithoutHeader set decode option, the input has no #!SILK_V3 file header, only length-prefixed blocks.
+// 设置输入没有文件头, 只有 [长度][内容] 数据块
+func WithoutHeader() internal.DecodeOpt {
+	return func(dc *internal.DecodeCfg) { dc.NoHeader = true }
+}
+
+// WithLengthPrefix set decode option, size(1, 2 or 4 bytes) and byte order of the block length prefix,
+// default 2 bytes little endian.
+// 设置数据块长度前缀的字节数(1, 2 或 4)和字节序, 默认 2 字节小端序
+func WithLengthPrefix(size int, bigEndian bool) internal.DecodeOpt {
+	return func(dc *internal.DecodeCfg) {
+		dc.LengthSize = size
+		dc.LengthBigEndian = bigEndian
+	}
+}
+
+// WithPacketLengths set decode option, the input is bare packets without header or length prefix,
+// lengths gives the length of each packet, a zero length means the packet is lost.
+// 设置输入是没有文件头和长度前缀的数据包, lengths 是每个包的长度, 长度为 0 表示丢包
+func WithPacketLengths(lengths []int) internal.DecodeOpt {
+	return func(dc *internal.DecodeCfg) { dc.PacketLengths = lengths }
+}
+
+// -------------------- Encode --------------------
+
+// NoHeader set output length-prefixed blocks only, without file header and footer, the Stx setting is ignored.
+// 只输出 [长度][内容] 数据块, 没有文件头和 footer(此时忽略 Stx 设置)
+func NoHeader(enable bool) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.NoHeader = enable }
+}
+
+// LengthPrefix set size(1, 2 or 4 bytes) and byte order of the block length prefix, default 2 bytes little endian.
+// The footer is not written when size is 1.
+// 设置数据块长度前缀的字节数(1, 2 或 4)和字节序, 默认 2 字节小端序; 1 字节时没有 footer
+func LengthPrefix(size int, bigEndian bool) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) {
+		ec.LengthSize = size
+		ec.LengthBigEndian = bigEndian
+	}
+}
+
+// PacketLengths set output bare packets without header or length prefix,
+// and the length of each packet is appended to lengths.
+// 只输出数据包本身, 每个包的长度追加到 lengths 中
+func PacketLengths(lengths *[]int) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.PacketLengths = lengths }
+}
@@ -0,0 +1,54 @@
+package silk
+
+import (
+	"io"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// -------------------- Decode --------------------
+
+// DecodeInt16 decodes silk src to 16bit samples.
+// 解码为 int16 采样点
+func DecodeInt16(src io.Reader, opts ...internal.DecodeOpt) ([]int16, error) {
+	return internal.DecodeInt16(src, opts...)
+}
+
+// DecodeFloat32 decodes silk src to float samples in [-1, 1).
+// 解码为 float32 采样点, 取值范围 [-1, 1)
+func DecodeFloat32(src io.Reader, opts ...internal.DecodeOpt) ([]float32, error) {
+	return internal.DecodeFloat32(src, opts...)
+}
+
+// WithBigEndianPCM set decode option, output big endian pcm instead of little endian.
+// 设置输出大端序的 pcm, 默认为小端序
+func WithBigEndianPCM(enable bool) internal.DecodeOpt {
+	return func(dc *internal.DecodeCfg) { dc.PCMBigEndian = enable }
+}
+
+// -------------------- Encode --------------------
+
+// EncodeInt16 encodes 16bit samples(interleaved when Channels is set) to silk.
+// 编码 int16 采样点(设置 Channels 时为交错排列的多声道)
+func EncodeInt16(samples []int16, opts ...internal.EncodeOpt) ([]byte, error) {
+	return internal.EncodeInt16(samples, opts...)
+}
+
+// EncodeFloat32 encodes float samples(interleaved when Channels is set) to silk,
+// samples out of [-1, 1) are clipped, see Dither.
+// 编码 float32 采样点(设置 Channels 时为交错排列的多声道), 超出 [-1, 1) 的值会被削波
+func EncodeFloat32(samples []float32, opts ...internal.EncodeOpt) ([]byte, error) {
+	return internal.EncodeFloat32(samples, opts...)
+}
+
+// Dither adds TPDF dither when EncodeFloat32 converts float samples to 16bit, default: false.
+// EncodeFloat32 转换为 16 位时添加三角分布抖动, 默认不添加
+func Dither(enable bool) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.Dither = enable }
+}
+
+// BigEndianPCM set the input pcm is big endian instead of little endian.
+// 设置输入的 pcm 为大端序, 默认为小端序
+func BigEndianPCM(enable bool) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.PCMBigEndian = enable }
+}
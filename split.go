@@ -0,0 +1,34 @@
+package silk
+
+import (
+	"io"
+	"time"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// Split splits a long silk or pcm recording into segments on silence, each segment is a complete silk file.
+// Pauses are found by the VAD flags of packets(pcm input is encoded first),
+// the output format(Stx, Ogg...) and the encode settings of pcm input are set by WithEncodeOpts.
+// 按静音将 silk 或 pcm 格式的长录音切分为多个 silk 文件, 每段都有完整的文件头和 footer(由 Stx 设置决定)
+func Split(src io.Reader, opts ...internal.SplitOpt) ([][]byte, error) {
+	return internal.Split(src, opts...)
+}
+
+// WithMaxDuration set max duration of each segment, default 60s(the limit of WeChat voice message)
+// 设置每段的最大时长，默认 60 秒
+func WithMaxDuration(d time.Duration) internal.SplitOpt {
+	return func(sc *internal.SplitCfg) { sc.MaxDuration = d }
+}
+
+// WithMinSilence set min duration of a pause to split at, default 300ms
+// 设置可以切分的最短停顿时长，默认 300 毫秒
+func WithMinSilence(d time.Duration) internal.SplitOpt {
+	return func(sc *internal.SplitCfg) { sc.MinSilence = d }
+}
+
+// WithEncodeOpts set encode options used by pcm input and the output format of segments.
+// 设置编码选项(用于 pcm 输入), 以及输出格式(Stx, Ogg 等)
+func WithEncodeOpts(opts ...internal.EncodeOpt) internal.SplitOpt {
+	return func(sc *internal.SplitCfg) { sc.Encode = append(sc.Encode, opts...) }
+}
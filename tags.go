@@ -0,0 +1,31 @@
+package silk
+
+import (
+	"io"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// Tags are key/value(UTF-8) pairs stored after the footer of silk v3 file, e.g. sender, conversation id, transcript.
+// Decode and other decoders stop at the footer and ignore them.
+// 保存在 silk v3 文件 footer 之后的标签(key/value, UTF-8), 如发送者、会话 ID、转写文本; 解码时忽略
+type Tags = internal.Tags
+
+// SetTags writes the tags after the footer. The output must have a footer,
+// so it can not be used with Stx, NoHeader, Ogg, PacketLengths or 1-byte length prefix.
+// 在 footer 之后写入标签, 不能与 Stx/NoHeader/Ogg/PacketLengths/1 字节长度前缀同时使用
+func SetTags(tags Tags) internal.EncodeOpt {
+	return func(ec *internal.EncodeCfg) { ec.Tags = tags }
+}
+
+// ReadTags reads the tags of silk v3 file, nil is returned when there is no tag.
+// 读取 silk v3 文件的标签, 没有标签时返回 nil
+func ReadTags(src io.Reader) (Tags, error) {
+	return internal.ReadTags(src)
+}
+
+// WriteTags copies silk v3 file from src to dst and replaces its tags without re-encoding, empty tags removes them.
+// 复制 silk v3 文件并替换其中的标签(不重新编码), tags 为空时删除标签
+func WriteTags(src io.Reader, dst io.Writer, tags Tags) error {
+	return internal.WriteTags(src, dst, tags)
+}
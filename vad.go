@@ -0,0 +1,22 @@
+package silk
+
+import (
+	"io"
+
+	"github.com/youthlin/silk/internal"
+)
+
+// SpeechSegment is a time range that contains speech.
+// 包含语音的时间段
+type SpeechSegment = internal.SpeechSegment
+
+// SpeechResult is the result of DetectSpeech: speech probability of each 20ms frame and the speech segments.
+// 语音检测结果: 每 20ms 一帧的语音概率, 以及语音段
+type SpeechResult = internal.SpeechResult
+
+// DetectSpeech runs the voice activity detector of the encoder(SKP_Silk_VAD) over 16bit little endian pcm,
+// it can be used to skip silence without encoding.
+// 对 16 位小端序的 pcm 数据进行语音活动检测(使用编码器内部的 VAD), 可用于跳过静音部分
+func DetectSpeech(pcm io.Reader, sampleRate int) (*SpeechResult, error) {
+	return internal.DetectSpeech(pcm, sampleRate)
+}